@@ -0,0 +1,284 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// archivedWord is a word of the day as the archive keeps it: the date it
+// was shown and the word itself, without anything only the page needs.
+type archivedWord struct {
+	Date        string   `json:"date"` // YYYY-MM-DD in the planner's timezone
+	Track       string   `json:"track,omitempty"`
+	Word        string   `json:"word"`
+	Language    string   `json:"language,omitempty"`
+	Pronounce   string   `json:"pronounce,omitempty"`
+	POS         string   `json:"pos,omitempty"`
+	Defs        []string `json:"defs"`
+	Example     string   `json:"example,omitempty"`
+	Translation string   `json:"translation,omitempty"`
+	Source      string   `json:"source,omitempty"`
+}
+
+// wotdArchive keeps every word of the day in a JSON file, oldest first, so
+// yesterday's word is still there tomorrow.
+type wotdArchive struct {
+	path string
+
+	mu    sync.RWMutex
+	words []archivedWord
+}
+
+var (
+	archivesMu sync.Mutex
+	archives   = make(map[string]*wotdArchive)
+)
+
+// openWotdArchive returns the archive kept at path, loading it the first
+// time. Every user of the same file shares one archive.
+func openWotdArchive(path string) *wotdArchive {
+	if path == "" {
+		path = "json/wotd-history.json"
+	}
+	archivesMu.Lock()
+	defer archivesMu.Unlock()
+
+	if archive, ok := archives[path]; ok {
+		return archive
+	}
+	archive := &wotdArchive{path: path}
+	data, err := ioutil.ReadFile(path)
+	if err == nil {
+		err = json.Unmarshal(data, &archive.words)
+	}
+	if err != nil && !os.IsNotExist(err) {
+		log.Printf("  INFO: Error reading word archive %s: %v\n", path, err)
+	}
+	archives[path] = archive
+	return archive
+}
+
+// record stores the word its track showed on date, replacing any earlier
+// word of the same track and day.
+func (a *wotdArchive) record(date string, word wotdType) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	i := len(a.words)
+	for i > 0 && a.words[i-1].Date > date {
+		i--
+	}
+	entry := archivedWord{
+		Date:        date,
+		Track:       word.Track,
+		Word:        word.Word,
+		Language:    word.Language,
+		Pronounce:   word.Pronounce,
+		POS:         word.POS,
+		Defs:        word.Defs,
+		Example:     word.Example,
+		Translation: word.Translation,
+		Source:      word.Source,
+	}
+	same := -1
+	for j := i - 1; j >= 0 && a.words[j].Date == date; j-- {
+		if a.words[j].Track == word.Track {
+			same = j
+		}
+	}
+	switch {
+	case same >= 0:
+		if a.words[same].Word == word.Word && a.words[same].Source == word.Source {
+			return
+		}
+		a.words[same] = entry
+	default:
+		a.words = append(a.words, archivedWord{})
+		copy(a.words[i+1:], a.words[i:])
+		a.words[i] = entry
+	}
+
+	err := a.save()
+	if err != nil {
+		log.Printf("  INFO: Error saving word archive %s: %v\n", a.path, err)
+	}
+}
+
+// save writes the archive through a temporary file so a crash never
+// leaves it half written.
+func (a *wotdArchive) save() error {
+	data, err := json.MarshalIndent(a.words, "", "    ")
+	if err != nil {
+		return err
+	}
+	tmp := a.path + ".tmp"
+	err = ioutil.WriteFile(tmp, data, 0644)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, a.path)
+}
+
+// search returns the words, newest first, whose word or definitions
+// contain query.
+func (a *wotdArchive) search(query string) []archivedWord {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	query = strings.ToLower(strings.TrimSpace(query))
+	var found []archivedWord
+	for i := len(a.words) - 1; i >= 0; i-- {
+		word := a.words[i]
+		text := strings.ToLower(word.Word + "\n" + strings.Join(word.Defs, "\n"))
+		if query == "" || strings.Contains(text, query) {
+			found = append(found, word)
+		}
+	}
+	return found
+}
+
+// since returns the words from date on, oldest first.
+func (a *wotdArchive) since(date string) []archivedWord {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	var found []archivedWord
+	for _, word := range a.words {
+		if word.Date >= date {
+			found = append(found, word)
+		}
+	}
+	return found
+}
+
+type wotdRecapSettings struct {
+	Day string // weekday to show the recap on, "Sunday" by default
+}
+
+// wotdRecapWidget lists the past week's words on one day of the week and
+// shows nothing on the others.
+type wotdRecapWidget struct {
+	settings wotdRecapSettings
+	day      time.Weekday
+	archive  *wotdArchive
+}
+
+func init() {
+	registerWidget("wotdRecap", func() Widget { return &wotdRecapWidget{} })
+}
+
+func (w *wotdRecapWidget) Name() string { return "wotdRecap" }
+
+func (w *wotdRecapWidget) Settings() interface{} { return &w.settings }
+
+func (w *wotdRecapWidget) Configure(config configStruct) error {
+	if w.settings.Day == "" {
+		w.settings.Day = "Sunday"
+	}
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		if strings.EqualFold(day.String(), w.settings.Day) {
+			w.day = day
+			w.archive = openWotdArchive(config.WotdArchive)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown recap day %q", w.settings.Day)
+}
+
+func (w *wotdRecapWidget) Interval() time.Duration { return time.Hour }
+
+func (w *wotdRecapWidget) Fetch() (interface{}, error) {
+	now := time.Now().In(plannerLocation)
+	if now.Weekday() != w.day {
+		return []archivedWord{}, nil
+	}
+	return w.archive.since(now.AddDate(0, 0, -6).Format("2006-01-02")), nil
+}
+
+func (w *wotdRecapWidget) Template() string {
+	return `{{if .}}
+<h2>{{t "This week's words"}}</h2>
+<ul id="wotdRecap">
+    {{- range .}}
+    <li><span class="recapDay">{{dayName .Date}}</span> <span class="recapWord">{{.Word}}</span>{{with .Track}} <span class="recapTrack">({{.}})</span>{{end}} &ndash; {{if .Defs}}{{erase (index .Defs 0) ":"}}{{end}}</li>
+    {{- end}}
+</ul>
+{{- end}}`
+}
+
+func (w *wotdRecapWidget) Health() error { return nil }
+
+// historyPageSize is how many words a page of /wotd/history shows.
+const historyPageSize = 20
+
+// historyPage is what templates/history.html is executed with.
+type historyPage struct {
+	Locale    *localeCatalog
+	ThemeHref string
+	Query     string
+	Words     []archivedWord
+	Page      int
+	Pages     int
+	Prev      int // previous page, 0 on the first
+	Next      int // next page, 0 on the last
+}
+
+// historyHandler serves /wotd/history?q=word&page=2.
+func historyHandler(config configStruct, archive *wotdArchive, d *display) http.HandlerFunc {
+	file := filepath.Join(filepath.Dir(config.HTMLFile), "history.html")
+	tmpl, err := template.New(filepath.Base(file)).Funcs(templateFuncs).ParseFiles(file)
+	if err != nil {
+		log.Fatalf("  FATAL: Error parsing %s: %v\n", file, err)
+	}
+
+	return func(w http.ResponseWriter, req *http.Request) {
+		query := req.URL.Query().Get("q")
+		words := archive.search(query)
+
+		pages := (len(words) + historyPageSize - 1) / historyPageSize
+		if pages == 0 {
+			pages = 1
+		}
+		page, err := strconv.Atoi(req.URL.Query().Get("page"))
+		if err != nil || page < 1 {
+			page = 1
+		}
+		if page > pages {
+			page = pages
+		}
+		start := (page - 1) * historyPageSize
+		end := start + historyPageSize
+		if end > len(words) {
+			end = len(words)
+		}
+
+		data := historyPage{
+			Locale:    activeLocale,
+			ThemeHref: d.themes.Href(),
+			Query:     query,
+			Words:     words[start:end],
+			Page:      page,
+			Pages:     pages,
+		}
+		if page > 1 {
+			data.Prev = page - 1
+		}
+		if page < pages {
+			data.Next = page + 1
+		}
+		err = tmpl.Execute(w, data)
+		if err != nil {
+			log.Println("  INFO: Error rendering word history:", err)
+		}
+	}
+}
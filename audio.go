@@ -0,0 +1,72 @@
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+)
+
+// audioDir is where downloaded pronunciation clips are kept and served from.
+func audioDir(config configStruct) string {
+	if config.AudioDir == "" {
+		return "audio"
+	}
+	return config.AudioDir
+}
+
+// mwAudioURL is where Merriam-Webster keeps a pronunciation clip. Clips sit
+// in a subdirectory named after the file: "bix" for files starting with
+// "bix", "gg" for those starting with "gg", "number" for those starting
+// with a digit or punctuation, and otherwise the file's first letter.
+// An empty file name has no URL.
+func mwAudioURL(base string, file string) string {
+	if file == "" {
+		return ""
+	}
+	var subdir string
+	first := []rune(file)[0]
+	switch {
+	case strings.HasPrefix(file, "bix"):
+		subdir = "bix"
+	case strings.HasPrefix(file, "gg"):
+		subdir = "gg"
+	case unicode.IsDigit(first) || unicode.IsPunct(first):
+		subdir = "number"
+	default:
+		subdir = string(first)
+	}
+	return strings.TrimSuffix(base, "/") + "/" + subdir + "/" + file
+}
+
+// cacheAudio downloads a clip into dir unless it is already there and
+// returns the path it is served at.
+func cacheAudio(dir string, url string, file string) (string, error) {
+	if file == "" || strings.ContainsAny(file, `/\`) || strings.HasPrefix(file, ".") {
+		return "", errors.New("invalid audio file name " + file)
+	}
+	path := filepath.Join(dir, file)
+	if _, err := os.Stat(path); err == nil {
+		return "/audio/" + file, nil
+	}
+
+	data, err := httpGetBytes(url)
+	if err != nil {
+		return "", err
+	}
+	err = os.MkdirAll(dir, 0755)
+	if err != nil {
+		return "", err
+	}
+	err = ioutil.WriteFile(path+".tmp", data, 0644)
+	if err != nil {
+		return "", err
+	}
+	err = os.Rename(path+".tmp", path)
+	if err != nil {
+		return "", err
+	}
+	return "/audio/" + file, nil
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+)
+
+type calendarSettings struct {
+	URL    string
+	Height string
+}
+
+// calendarWidget embeds a web calendar, such as a Google Calendar, in an
+// iframe. The calendar refreshes itself, so Fetch only hands the settings
+// to the template.
+type calendarWidget struct {
+	settings calendarSettings
+}
+
+func init() {
+	registerWidget("calendar", func() Widget { return &calendarWidget{} })
+}
+
+func (w *calendarWidget) Name() string { return "calendar" }
+
+func (w *calendarWidget) Settings() interface{} { return &w.settings }
+
+func (w *calendarWidget) Configure(config configStruct) error {
+	if w.settings.Height == "" {
+		w.settings.Height = "465px"
+	}
+	w.settings.URL = calendarURL(w.settings.URL, config.Timezone)
+	return nil
+}
+
+func (w *calendarWidget) Interval() time.Duration { return 24 * time.Hour }
+
+func (w *calendarWidget) Fetch() (interface{}, error) { return w.settings, nil }
+
+func (w *calendarWidget) Template() string {
+	return `<iframe src="{{.URL}}" style="border: 0; width: 100%; height: {{.Height}}"></iframe>`
+}
+
+func (w *calendarWidget) Health() error {
+	if w.settings.URL == "" {
+		return errors.New("no calendar url configured")
+	}
+	return nil
+}
+
+// calendarURL gives a Google Calendar URL the planner's timezone, so its
+// events line up with the clock. A ctz the URL already has is kept.
+func calendarURL(raw string, timezone string) string {
+	u, err := url.Parse(raw)
+	if err != nil || timezone == "" || !strings.HasSuffix(u.Host, "calendar.google.com") {
+		return raw
+	}
+	query := u.Query()
+	if query.Get("ctz") != "" {
+		return raw
+	}
+	query.Set("ctz", timezone)
+	u.RawQuery = query.Encode()
+	return u.String()
+}
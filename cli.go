@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"runtime"
+	"time"
+)
+
+// version is set at build time with -ldflags "-X main.version=1.2.3".
+var version = "dev"
+
+func usage(flags *flag.FlagSet) {
+	fmt.Fprintf(flags.Output(), `Usage: planner [--config path] <command> [arguments]
+
+Commands:
+  serve                      run the planner and its web server (the default)
+  fetch <widget> [--once]    fetch one widget and print its data as JSON
+  render [--profile name] [--out file.png]
+                             draw a display profile to a PNG
+  print [--paper letter|a4] [--out file.pdf]
+                             write the daily sheet as a PDF
+  tui [--server url]         show a running planner in the terminal
+  onthisday import [--source url|file] [--days n] [--date MM-DD]
+                             add days to the "on this day" dataset
+  config validate            check the configuration and exit
+  version                    print the version and exit
+
+Flags:
+`)
+	flags.PrintDefaults()
+}
+
+// runCommand dispatches one command of the command line.
+func runCommand(command string, args []string, configPath string) {
+	switch command {
+	case "version":
+		fmt.Printf("planner %s (%s %s/%s)\n", version, runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	case "serve":
+		serve(setup(configPath))
+	case "fetch":
+		fetchCommand(setup(configPath), args)
+	case "render":
+		renderOnce(setup(configPath), args)
+	case "print":
+		printOnce(setup(configPath), args)
+	case "tui":
+		runTUI(setup(configPath), args)
+	case "onthisday":
+		onThisDayCommand(setup(configPath), args)
+	case "config":
+		if len(args) != 1 || args[0] != "validate" {
+			log.Fatalln("  FATAL: Usage: planner config validate")
+		}
+		validateConfig(configPath)
+	default:
+		log.Fatalf("  FATAL: Unknown command %q, see planner --help\n", command)
+	}
+}
+
+// fetchCommand implements "planner fetch": run a single widget and print
+// its data as JSON, once or on the widget's schedule, without the server.
+func fetchCommand(config configStruct, args []string) {
+	if len(args) == 0 {
+		log.Fatalln("  FATAL: Usage: planner fetch <widget> [--once]")
+	}
+	name := args[0]
+	if name == "photo" {
+		name = "photos"
+	}
+	flags := flag.NewFlagSet("fetch", flag.ExitOnError)
+	once := flags.Bool("once", false, "fetch once and exit")
+	flags.Parse(args[1:])
+
+	widget := loadWidgets(config, []string{name})[0]
+	for {
+		data, err := widget.Fetch()
+		if err != nil {
+			log.Fatalf("  FATAL: Error fetching %s: %v\n", name, err)
+		}
+		out, err := json.MarshalIndent(data, "", "    ")
+		if err != nil {
+			log.Fatalf("  FATAL: Error encoding %s: %v\n", name, err)
+		}
+		fmt.Println(string(out))
+
+		if *once {
+			return
+		}
+		time.Sleep(widget.Interval())
+	}
+}
+
+// validateConfig implements "planner config validate". Anything that would
+// stop the server from starting is fatal here too; keys the planner does not
+// know, which are otherwise silently ignored, and widgets that report
+// themselves unhealthy are listed as problems.
+func validateConfig(path string) {
+	config := setup(path)
+	var problems []string
+
+	raw, _ := ioutil.ReadFile(path)
+	err := strictUnmarshal(raw, &configStruct{})
+	if err != nil {
+		problems = append(problems, fmt.Sprintf("%s: %v", path, err))
+	}
+	for name, settings := range config.Widgets {
+		factory, ok := widgetFactories[name]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("widgets: unknown widget %q", name))
+			continue
+		}
+		err = strictUnmarshal(settings, factory().Settings())
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("widgets.%s: %v", name, err))
+		}
+	}
+
+	displays := loadDisplays(config)
+	widgets := loadWidgets(config, displayWidgets(displays))
+	newRenderer(config, widgets)
+
+	for name, d := range displays {
+		for _, theme := range []string{d.themes.config.Day, d.themes.config.Night} {
+			for _, month := range []time.Month{time.January, time.April, time.July, time.October} {
+				resolved := d.themes.resolve(theme, time.Date(2000, month, 1, 0, 0, 0, 0, time.UTC))
+				_, err = d.themes.css(resolved)
+				if err != nil {
+					problems = append(problems, fmt.Sprintf("display %q: theme %q: %v", name, resolved, err))
+					break
+				}
+			}
+		}
+	}
+	for _, widget := range widgets {
+		err = widget.Health()
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("widget %q: %v", widget.Name(), err))
+		}
+	}
+
+	if len(problems) > 0 {
+		for _, problem := range problems {
+			fmt.Println(problem)
+		}
+		os.Exit(1)
+	}
+	fmt.Printf("%s is valid: %d displays, %d widgets\n", path, len(displays), len(widgets))
+}
+
+func strictUnmarshal(data []byte, v interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(v)
+}
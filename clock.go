@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"time"
+)
+
+type clockSettings struct {
+	Format string // "24h" or "12h"
+}
+
+// clockData is the clock as shown on the page.
+type clockData struct {
+	Date string
+	Time string
+}
+
+// clockWidget is the date and time header. The server keeps it in the
+// planner's timezone and pushes it to the page every minute.
+type clockWidget struct {
+	settings clockSettings
+}
+
+func init() {
+	registerWidget("clock", func() Widget { return &clockWidget{} })
+}
+
+func (w *clockWidget) Name() string { return "clock" }
+
+func (w *clockWidget) Settings() interface{} { return &w.settings }
+
+func (w *clockWidget) Configure(config configStruct) error {
+	switch w.settings.Format {
+	case "":
+		w.settings.Format = "24h"
+	case "12h", "24h":
+	default:
+		return fmt.Errorf("unknown clock format %q", w.settings.Format)
+	}
+	return nil
+}
+
+// Interval lines the next fetch up with the start of the next minute.
+func (w *clockWidget) Interval() time.Duration {
+	now := time.Now()
+	return now.Truncate(time.Minute).Add(time.Minute).Sub(now)
+}
+
+func (w *clockWidget) Fetch() (interface{}, error) {
+	now := time.Now().In(plannerLocation)
+
+	clock := clockData{Date: activeLocale.formatDate(now)}
+	if w.settings.Format == "12h" {
+		clock.Time = now.Format("3:04") + " " + activeLocale.translate(now.Format("PM"))
+	} else {
+		clock.Time = now.Format("15:04")
+	}
+	return clock, nil
+}
+
+func (w *clockWidget) Template() string {
+	return `<h1><span id="date">{{.Date}}</span>&nbsp;/&nbsp;<span id="time">{{.Time}}</span></h1>`
+}
+
+func (w *clockWidget) Health() error { return nil }
+
+func (w *clockWidget) ImageHeight(c *canvas, width int, data interface{}) int {
+	return lineHeight(3 * c.scale)
+}
+
+func (w *clockWidget) DrawImage(c *canvas, area image.Rectangle, data interface{}) {
+	clock, ok := data.(clockData)
+	if !ok {
+		return
+	}
+	text := clock.Date + " / " + clock.Time
+	c.textCentered(area, area.Min.Y, text, fitScale(text, area.Dx(), 3*c.scale), inkBlack)
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"bytes"
+	"embed"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+//go:embed dictionary/*.json
+var bundledDictionary embed.FS
+
+// dictionaryWord is one word of a local dictionary file. In a CSV file the
+// columns carry the same names, with definitions separated by "|". Words of
+// a foreign language carry their gender and translations.
+type dictionaryWord struct {
+	Word               string       `json:"word"`
+	Pronunciation      string       `json:"pronunciation"`
+	PartOfSpeech       string       `json:"partOfSpeech"`
+	Gender             string       `json:"gender"`
+	Definitions        []string     `json:"definitions"`
+	Translation        translations `json:"translation"`
+	Example            string       `json:"example"`
+	ExampleTranslation translations `json:"exampleTranslation"`
+}
+
+// translations are keyed by language code: {"en": "house", "de": "Haus"}.
+// A plain string, as in older custom lists, is used whatever the household's
+// language.
+type translations map[string]string
+
+func (t *translations) UnmarshalJSON(data []byte) error {
+	var text string
+	if json.Unmarshal(data, &text) == nil {
+		*t = translations{"": text}
+		return nil
+	}
+	var byLanguage map[string]string
+	if err := json.Unmarshal(data, &byLanguage); err != nil {
+		return err
+	}
+	*t = byLanguage
+	return nil
+}
+
+// in is the translation into language, or the plain one.
+func (t translations) in(language string) string {
+	if text, ok := t[language]; ok {
+		return text
+	}
+	return t[""]
+}
+
+// wotd is the word as the widget shows it, translated into the household's
+// language.
+func (entry dictionaryWord) wotd() wotdType {
+	return wotdType{
+		Word:               entry.Word,
+		Pronounce:          entry.Pronunciation,
+		POS:                entry.PartOfSpeech,
+		Gender:             entry.Gender,
+		Defs:               entry.Definitions,
+		Translation:        entry.Translation.in(activeLocale.Language),
+		Example:            entry.Example,
+		ExampleTranslation: entry.ExampleTranslation.in(activeLocale.Language),
+	}
+}
+
+// wordList is a custom list, such as the week's spelling words, that takes
+// over from the dictionary between From and Until (YYYY-MM-DD, inclusive).
+type wordList struct {
+	File  string
+	From  string
+	Until string
+}
+
+// localWordSource picks the day's word from a dictionary file, or from the
+// bundled dictionary when no file is given. Words are taken in the order of
+// the file, one per day, so every display in the house agrees without
+// talking to the others. With a language, the words are in that language,
+// and the bundled dictionary is the bilingual list for it.
+type localWordSource struct {
+	file     string
+	lists    []wordList
+	language string
+}
+
+func (s *localWordSource) Name() string { return "local" }
+
+func (s *localWordSource) Health() error {
+	words, err := s.dictionary(time.Now())
+	if err != nil || s.language == "" || s.language == activeLocale.Language {
+		return err
+	}
+	for _, word := range words {
+		if word.Translation == "" {
+			return fmt.Errorf("no %s translation of %q in the %s word list", activeLocale.Language, word.Word, s.language)
+		}
+	}
+	return nil
+}
+
+func (s *localWordSource) Word() (wotdType, error) {
+	now := time.Now()
+	words, err := s.dictionary(now)
+	if err != nil {
+		return wotdType{}, err
+	}
+	word := words[dayNumber(now)%len(words)]
+	word.Language = s.language
+	return word, nil
+}
+
+// dayNumber counts days since 1970 by the calendar in the planner's
+// timezone, so every display agrees on "today".
+func dayNumber(now time.Time) int {
+	year, month, day := now.In(plannerLocation).Date()
+	return int(time.Date(year, month, day, 0, 0, 0, 0, time.UTC).Unix() / 86400)
+}
+
+// dictionary loads the list in effect at now.
+func (s *localWordSource) dictionary(now time.Time) ([]wotdType, error) {
+	today := now.In(plannerLocation).Format("2006-01-02")
+	for _, list := range s.lists {
+		if list.From <= today && (list.Until == "" || today <= list.Until) {
+			return loadDictionary(list.File, s.language)
+		}
+	}
+	return loadDictionary(s.file, s.language)
+}
+
+// loadDictionary reads a JSON or CSV dictionary, chosen by the file's
+// extension. An empty name is the bundled dictionary, or the bundled list
+// for language.
+func loadDictionary(file string, language string) ([]wotdType, error) {
+	var data []byte
+	var err error
+	if file == "" {
+		file = "dictionary/words.json"
+		if language != "" {
+			file = "dictionary/" + language + ".json"
+		}
+		data, err = bundledDictionary.ReadFile(file)
+	} else {
+		data, err = ioutil.ReadFile(file)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []dictionaryWord
+	if strings.EqualFold(filepath.Ext(file), ".csv") {
+		entries, err = parseDictionaryCSV(data)
+	} else {
+		err = json.Unmarshal(data, &entries)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %v", file, err)
+	}
+
+	var words []wotdType
+	for _, entry := range entries {
+		if entry.Word == "" {
+			continue
+		}
+		words = append(words, entry.wotd())
+	}
+	if len(words) == 0 {
+		return nil, fmt.Errorf("%s has no words", file)
+	}
+	return words, nil
+}
+
+// parseDictionaryCSV reads a CSV file whose header row names its columns.
+// Only the word column is required.
+func parseDictionaryCSV(data []byte) ([]dictionaryWord, error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := columns["word"]; !ok {
+		return nil, fmt.Errorf("no word column")
+	}
+	field := func(record []string, name string) string {
+		i, ok := columns[strings.ToLower(name)]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	var words []dictionaryWord
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return words, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		word := dictionaryWord{
+			Word:               field(record, "word"),
+			Pronunciation:      field(record, "pronunciation"),
+			PartOfSpeech:       field(record, "partOfSpeech"),
+			Gender:             field(record, "gender"),
+			Translation:        translations{"": field(record, "translation")},
+			Example:            field(record, "example"),
+			ExampleTranslation: translations{"": field(record, "exampleTranslation")},
+		}
+		for _, def := range strings.Split(field(record, "definitions"), "|") {
+			if def = strings.TrimSpace(def); def != "" {
+				word.Definitions = append(word.Definitions, def)
+			}
+		}
+		words = append(words, word)
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// sseEvent is one update pushed to the browser.
+type sseEvent struct {
+	ID   uint64
+	Name string
+	Data string
+}
+
+// eventHub fans updates out to every connected browser and keeps the latest
+// update per key so a (re)connecting page can replay current state.
+type eventHub struct {
+	mu      sync.Mutex
+	nextID  uint64
+	clients map[chan sseEvent]bool
+	latest  map[string]sseEvent
+}
+
+var events = newEventHub()
+
+func newEventHub() *eventHub {
+	return &eventHub{
+		clients: make(map[chan sseEvent]bool),
+		latest:  make(map[string]sseEvent),
+	}
+}
+
+// publish sends payload, encoded as JSON, to every client as event name.
+// Only the latest event per key is kept for replay; an event with an empty
+// key is not replayed at all, for one-off signals that would be wrong to
+// repeat on reconnect.
+func (h *eventHub) publish(name string, key string, payload interface{}) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		log.Printf("  INFO: Error encoding %s event: %v\n", name, err)
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.nextID++
+	ev := sseEvent{ID: h.nextID, Name: name, Data: string(data)}
+	if key != "" {
+		h.latest[key] = ev
+	}
+
+	for client := range h.clients {
+		select {
+		case client <- ev:
+		default:
+			// A client this far behind is dropped; the browser reconnects
+			// and gets the latest state replayed.
+			delete(h.clients, client)
+			close(client)
+		}
+	}
+}
+
+// subscribe registers a new client and returns its channel together with the
+// latest event of every key, oldest first.
+func (h *eventHub) subscribe() (chan sseEvent, []sseEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	client := make(chan sseEvent, 16)
+	h.clients[client] = true
+
+	replay := make([]sseEvent, 0, len(h.latest))
+	for _, ev := range h.latest {
+		replay = append(replay, ev)
+	}
+	sort.Slice(replay, func(i, j int) bool { return replay[i].ID < replay[j].ID })
+
+	return client, replay
+}
+
+func (h *eventHub) unsubscribe(client chan sseEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.clients[client] {
+		delete(h.clients, client)
+		close(client)
+	}
+}
+
+// ServeHTTP streams events to a browser EventSource until it disconnects.
+func (h *eventHub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	client, replay := h.subscribe()
+	defer h.unsubscribe(client)
+
+	// Tell the browser how long to wait before reconnecting.
+	fmt.Fprint(w, "retry: 5000\n\n")
+	for _, ev := range replay {
+		writeEvent(w, ev)
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(30 * time.Second)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case ev, open := <-client:
+			if !open {
+				return
+			}
+			writeEvent(w, ev)
+			flusher.Flush()
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": ping\n\n")
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func writeEvent(w http.ResponseWriter, ev sseEvent) {
+	fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", ev.ID, ev.Name, ev.Data)
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed locales/*.json
+var bundledLocales embed.FS
+
+// localeCatalog holds the translations and formatting rules of one locale.
+// Strings maps the English UI text to its translation; text missing from
+// the catalog is shown in English.
+type localeCatalog struct {
+	Language   string            `json:"language"`
+	Days       []string          `json:"days"`
+	Months     []string          `json:"months"`
+	DateFormat string            `json:"dateFormat"`
+	Decimal    string            `json:"decimal"`
+	Thousands  string            `json:"thousands"`
+	Strings    map[string]string `json:"strings"`
+}
+
+// activeLocale is set once at startup, before any widget is configured.
+var activeLocale *localeCatalog
+
+// loadLocale reads a catalog such as "de" or "es-MX", preferring dir over
+// the bundled catalogs and falling back from a regional variant to its
+// base language.
+func loadLocale(name string, dir string) (*localeCatalog, error) {
+	if name == "" {
+		name = "en"
+	}
+	candidates := []string{name}
+	if i := strings.IndexAny(name, "-_"); i > 0 {
+		candidates = append(candidates, name[:i])
+	}
+
+	for _, candidate := range candidates {
+		data, err := readLocale(candidate, dir)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var catalog localeCatalog
+		err = json.Unmarshal(data, &catalog)
+		if err != nil {
+			return nil, fmt.Errorf("unmarshaling locale %q: %v", candidate, err)
+		}
+		if len(catalog.Days) != 7 || len(catalog.Months) != 12 {
+			return nil, fmt.Errorf("locale %q needs 7 days and 12 months", candidate)
+		}
+		if catalog.Language == "" {
+			catalog.Language = candidate
+		}
+		if catalog.Decimal == "" {
+			catalog.Decimal = "."
+		}
+		return &catalog, nil
+	}
+	return nil, fmt.Errorf("no catalog for locale %q", name)
+}
+
+func readLocale(name string, dir string) ([]byte, error) {
+	if strings.ContainsAny(name, `/\.`) {
+		return nil, fmt.Errorf("invalid locale name %q", name)
+	}
+	if dir != "" {
+		data, err := ioutil.ReadFile(filepath.Join(dir, name+".json"))
+		if !os.IsNotExist(err) {
+			return data, err
+		}
+	}
+	return bundledLocales.ReadFile("locales/" + name + ".json")
+}
+
+func (l *localeCatalog) translate(text string) string {
+	if translated, ok := l.Strings[text]; ok && translated != "" {
+		return translated
+	}
+	return text
+}
+
+func (l *localeCatalog) weekday(tm time.Time) string {
+	return l.Days[tm.Weekday()]
+}
+
+// formatDate renders tm with the catalog's dateFormat, whose placeholders
+// are {weekday}, {day}, {month} and {year}.
+func (l *localeCatalog) formatDate(tm time.Time) string {
+	return strings.NewReplacer(
+		"{weekday}", l.Days[tm.Weekday()],
+		"{day}", strconv.Itoa(tm.Day()),
+		"{month}", l.Months[tm.Month()-1],
+		"{year}", strconv.Itoa(tm.Year()),
+	).Replace(l.DateFormat)
+}
+
+// formatNumber rounds x to places decimals using the locale's separators.
+func (l *localeCatalog) formatNumber(x float64, places int) string {
+	str := strconv.FormatFloat(math.Abs(x), 'f', places, 64)
+	whole, fraction := str, ""
+	if i := strings.IndexByte(str, '.'); i >= 0 {
+		whole, fraction = str[:i], str[i+1:]
+	}
+
+	var grouped strings.Builder
+	for i, digit := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			grouped.WriteString(l.Thousands)
+		}
+		grouped.WriteRune(digit)
+	}
+
+	result := grouped.String()
+	if fraction != "" {
+		result += l.Decimal + fraction
+	}
+	if x < 0 && strings.Trim(str, "0.") != "" {
+		result = "-" + result
+	}
+	return result
+}
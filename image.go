@@ -0,0 +1,468 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"log"
+	"math"
+	"os"
+)
+
+// renderSettings controls how a display is drawn as an image for e-ink
+// frames. Depth is the bits per pixel of the PNG: 1, 2 or 4 for 2, 4 or 16
+// greys, or 8 for plain greyscale. Dither is "floyd-steinberg" or "none".
+// Scale multiplies the 5x7 font; zero picks one from the width.
+type renderSettings struct {
+	Width  int
+	Height int
+	Depth  int
+	Dither string
+	Scale  int
+}
+
+// imageDrawer is implemented by widgets that can draw themselves for the
+// PNG renderer. Widgets without it are left blank in the image.
+type imageDrawer interface {
+	// ImageHeight is the height the widget needs when drawn at width.
+	ImageHeight(c *canvas, width int, data interface{}) int
+
+	// DrawImage draws the widget's data within area.
+	DrawImage(c *canvas, area image.Rectangle, data interface{})
+}
+
+func (s *renderSettings) applyDefaults() error {
+	if s.Width <= 0 {
+		s.Width = 800
+	}
+	if s.Height <= 0 {
+		s.Height = 600
+	}
+	switch s.Depth {
+	case 0:
+		s.Depth = 1
+	case 1, 2, 4, 8:
+	default:
+		return fmt.Errorf("unsupported depth %d, use 1, 2, 4 or 8", s.Depth)
+	}
+	switch s.Dither {
+	case "":
+		s.Dither = "floyd-steinberg"
+	case "floyd-steinberg", "none":
+	default:
+		return fmt.Errorf("unknown dither %q", s.Dither)
+	}
+	if s.Scale <= 0 {
+		s.Scale = s.Width / 400
+		if s.Scale < 1 {
+			s.Scale = 1
+		}
+	}
+	return nil
+}
+
+// canvas is a greyscale drawing surface. Drawing is clipped to clip so a
+// widget cannot spill into its neighbours.
+type canvas struct {
+	img   *image.Gray
+	clip  image.Rectangle
+	scale int
+}
+
+const (
+	inkBlack uint8 = 0
+	inkGrey  uint8 = 128
+	inkWhite uint8 = 255
+)
+
+func (c *canvas) set(x, y int, g uint8) {
+	if image.Pt(x, y).In(c.clip) {
+		c.img.SetGray(x, y, color.Gray{Y: g})
+	}
+}
+
+func (c *canvas) fill(r image.Rectangle, g uint8) {
+	r = r.Intersect(c.clip)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			c.img.SetGray(x, y, color.Gray{Y: g})
+		}
+	}
+}
+
+// text draws s with its top-left corner at (x, y) and returns its width.
+func (c *canvas) text(x, y int, s string, scale int, g uint8) int {
+	s = glyphText(s)
+	for i := 0; i < len(s); i++ {
+		glyph := glyphs[s[i]-' ']
+		gx := x + i*(glyphWidth+glyphSpacing)*scale
+		for col := 0; col < glyphWidth; col++ {
+			for row := 0; row < glyphHeight; row++ {
+				if glyph[col]&(1<<uint(row)) == 0 {
+					continue
+				}
+				c.fill(image.Rect(gx+col*scale, y+row*scale, gx+(col+1)*scale, y+(row+1)*scale), g)
+			}
+		}
+	}
+	return textWidth(s, scale)
+}
+
+// textCentered draws s centred horizontally within area.
+func (c *canvas) textCentered(area image.Rectangle, y int, s string, scale int, g uint8) {
+	x := area.Min.X + (area.Dx()-textWidth(s, scale))/2
+	c.text(x, y, s, scale, g)
+}
+
+// paragraph draws s wrapped to width and returns the height used.
+func (c *canvas) paragraph(x, y, width int, s string, scale int, g uint8) int {
+	lines := wrapText(s, width, scale)
+	for i, line := range lines {
+		c.text(x, y+i*lineHeight(scale), line, scale, g)
+	}
+	return len(lines) * lineHeight(scale)
+}
+
+func (c *canvas) line(x0, y0, x1, y1, thickness int, g uint8) {
+	steps := int(math.Max(math.Abs(float64(x1-x0)), math.Abs(float64(y1-y0))))
+	if steps == 0 {
+		steps = 1
+	}
+	for i := 0; i <= steps; i++ {
+		x := x0 + (x1-x0)*i/steps
+		y := y0 + (y1-y0)*i/steps
+		c.fill(image.Rect(x-thickness/2, y-thickness/2, x-thickness/2+thickness, y-thickness/2+thickness), g)
+	}
+}
+
+// disc fills a circle.
+func (c *canvas) disc(cx, cy, r int, g uint8) {
+	for y := -r; y <= r; y++ {
+		for x := -r; x <= r; x++ {
+			if x*x+y*y <= r*r {
+				c.set(cx+x, cy+y, g)
+			}
+		}
+	}
+}
+
+// icon draws a forecast icon, named as in the Dark Sky API, within a
+// square of the given size.
+func (c *canvas) icon(name string, x, y, size int) {
+	u := size / 16
+	if u < 1 {
+		u = 1
+	}
+	cx, cy := x+size/2, y+size/2
+
+	sun := func(sx, sy, r int) {
+		for i := 0; i < 8; i++ {
+			angle := float64(i) * math.Pi / 4
+			dx, dy := math.Cos(angle), math.Sin(angle)
+			c.line(sx+int(dx*float64(r+2*u)), sy+int(dy*float64(r+2*u)),
+				sx+int(dx*float64(r+4*u)), sy+int(dy*float64(r+4*u)), u, inkBlack)
+		}
+		c.disc(sx, sy, r, inkBlack)
+	}
+	moon := func(mx, my, r int) {
+		c.disc(mx, my, r, inkBlack)
+		c.disc(mx+r/2, my-r/3, r, inkWhite)
+	}
+	cloud := func(top int, g uint8) {
+		c.disc(x+5*u, top+6*u, 3*u, g)
+		c.disc(x+8*u, top+4*u, 4*u, g)
+		c.disc(x+11*u, top+6*u, 3*u, g)
+		c.fill(image.Rect(x+5*u, top+6*u, x+11*u, top+9*u), g)
+	}
+	below := func(draw func(px, py int)) {
+		for i := 0; i < 3; i++ {
+			draw(x+(5+3*i)*u, y+12*u)
+		}
+	}
+
+	switch name {
+	case "clear-day":
+		sun(cx, cy, 3*u)
+	case "clear-night":
+		moon(cx, cy, 5*u)
+	case "partly-cloudy-day":
+		sun(x+6*u, y+5*u, 2*u)
+		cloud(y+5*u, inkGrey)
+	case "partly-cloudy-night":
+		moon(x+6*u, y+5*u, 3*u)
+		cloud(y+5*u, inkGrey)
+	case "cloudy":
+		cloud(y+3*u, inkBlack)
+	case "rain":
+		cloud(y+1*u, inkBlack)
+		below(func(px, py int) { c.line(px, py, px-u, py+3*u, u, inkBlack) })
+	case "snow":
+		cloud(y+1*u, inkBlack)
+		below(func(px, py int) { c.text(px-u, py, "*", maxInt(u/2, 1), inkBlack) })
+	case "sleet":
+		cloud(y+1*u, inkBlack)
+		below(func(px, py int) { c.disc(px, py+u, u, inkBlack) })
+	case "wind":
+		for i := 0; i < 3; i++ {
+			c.line(x+2*u, y+(5+3*i)*u, x+(12-2*i)*u, y+(5+3*i)*u, u, inkBlack)
+		}
+	case "fog":
+		for i := 0; i < 4; i++ {
+			c.line(x+(2+i%2)*u, y+(4+3*i)*u, x+(14-i%2)*u, y+(4+3*i)*u, u, inkGrey)
+		}
+	default:
+		c.text(cx-textWidth("?", 2*u)/2, cy-glyphHeight*u, "?", 2*u, inkBlack)
+	}
+}
+
+// fitScale is the largest scale up to max at which s fits in width.
+func fitScale(s string, width int, max int) int {
+	scale := max
+	for scale > 1 && textWidth(s, scale) > width {
+		scale--
+	}
+	return scale
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// placedRegion is a layout region resolved to grid cells.
+type placedRegion struct {
+	layoutRegion
+	row, col, rowSpan, colSpan int
+}
+
+// placeRegions resolves the layout the way a browser's CSS grid would in
+// its default (sparse) auto-placement: explicit positions are honoured and
+// the rest fill the next free cells in reading order.
+func placeRegions(layout layoutConfig) ([]placedRegion, int) {
+	columns := layout.Columns
+	if columns <= 0 {
+		columns = 1
+	}
+	taken := make(map[[2]int]bool)
+	fits := func(row, col, rowSpan, colSpan int) bool {
+		if col < 1 || col+colSpan-1 > columns {
+			return false
+		}
+		for r := row; r < row+rowSpan; r++ {
+			for c := col; c < col+colSpan; c++ {
+				if taken[[2]int{r, c}] {
+					return false
+				}
+			}
+		}
+		return true
+	}
+
+	var placed []placedRegion
+	rows := 0
+	cursorRow, cursorCol := 1, 1
+	for _, region := range layout.Regions {
+		p := placedRegion{layoutRegion: region, rowSpan: maxInt(region.RowSpan, 1), colSpan: maxInt(region.ColumnSpan, 1)}
+		if p.colSpan > columns {
+			p.colSpan = columns
+		}
+
+		// A column past the edge of the grid is pulled back so the region
+		// still fits on it.
+		column := region.Column
+		if column > columns-p.colSpan+1 {
+			column = columns - p.colSpan + 1
+		}
+
+		auto := false
+		switch {
+		case region.Row > 0 && column > 0:
+			p.row, p.col = region.Row, column
+		case region.Row > 0:
+			p.row, p.col = region.Row, 1
+			for p.col <= columns && !fits(p.row, p.col, p.rowSpan, p.colSpan) {
+				p.col++
+			}
+			// A row with no room left places the region like any other.
+			auto = p.col > columns
+		case column > 0:
+			p.row, p.col = cursorRow, column
+			for !fits(p.row, p.col, p.rowSpan, p.colSpan) {
+				p.row++
+			}
+		default:
+			auto = true
+		}
+		if auto {
+			p.row, p.col = cursorRow, cursorCol
+			for !fits(p.row, p.col, p.rowSpan, p.colSpan) {
+				p.col++
+				if p.col > columns {
+					p.row, p.col = p.row+1, 1
+				}
+			}
+			cursorRow, cursorCol = p.row, p.col+p.colSpan
+		}
+
+		for r := p.row; r < p.row+p.rowSpan; r++ {
+			for c := p.col; c < p.col+p.colSpan; c++ {
+				taken[[2]int{r, c}] = true
+			}
+		}
+		if last := p.row + p.rowSpan - 1; last > rows {
+			rows = last
+		}
+		placed = append(placed, p)
+	}
+	return placed, rows
+}
+
+// drawDisplay draws a display's layout with the current widget data.
+// E-ink panels are drawn black on white whatever the display's theme.
+func drawDisplay(d *display, state plannerState, widgets map[string]Widget) *image.Gray {
+	settings := d.Profile.Render
+	img := image.NewGray(image.Rect(0, 0, settings.Width, settings.Height))
+	c := &canvas{img: img, clip: img.Bounds(), scale: settings.Scale}
+	c.fill(img.Bounds(), inkWhite)
+
+	layout := d.Profile.Layout
+	placed, rows := placeRegions(layout)
+	if rows == 0 {
+		return img
+	}
+	columns := maxInt(layout.Columns, 1)
+	gap := 4 * settings.Scale
+	colWidth := (settings.Width - gap*(columns+1)) / columns
+
+	// Each row is as tall as the tallest single-row region in it; whatever
+	// height is left over goes to the last row.
+	heights := make([]int, rows+1)
+	for _, p := range placed {
+		drawer, ok := widgets[p.Widget].(imageDrawer)
+		data, hasData := state.Data[p.Widget]
+		if !ok || !hasData || p.rowSpan != 1 {
+			continue
+		}
+		width := p.colSpan*colWidth + (p.colSpan-1)*gap
+		if h := drawer.ImageHeight(c, width, data); h > heights[p.row] {
+			heights[p.row] = h
+		}
+	}
+	used := gap
+	for row := 1; row <= rows; row++ {
+		used += heights[row] + gap
+	}
+	if used < settings.Height {
+		heights[rows] += settings.Height - used
+	}
+
+	tops := make([]int, rows+2)
+	tops[1] = gap
+	for row := 1; row <= rows; row++ {
+		tops[row+1] = tops[row] + heights[row] + gap
+	}
+
+	for _, p := range placed {
+		drawer, ok := widgets[p.Widget].(imageDrawer)
+		data, hasData := state.Data[p.Widget]
+		if !ok || !hasData {
+			continue
+		}
+		left := gap + (p.col-1)*(colWidth+gap)
+		area := image.Rect(left, tops[p.row], left+p.colSpan*colWidth+(p.colSpan-1)*gap, tops[p.row+p.rowSpan]-gap)
+		c.clip = area.Intersect(img.Bounds())
+		drawer.DrawImage(c, area, data)
+	}
+	return img
+}
+
+// quantize reduces img to 2^depth evenly spaced greys, optionally spreading
+// the rounding error with Floyd-Steinberg dithering.
+func quantize(img *image.Gray, depth int, dither bool) *image.Paletted {
+	levels := 1 << uint(depth)
+	palette := make(color.Palette, levels)
+	for i := range palette {
+		palette[i] = color.Gray{Y: uint8(i * 255 / (levels - 1))}
+	}
+
+	bounds := img.Bounds()
+	out := image.NewPaletted(bounds, palette)
+	width := bounds.Dx()
+	current := make([]float64, width+2)
+	next := make([]float64, width+2)
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			i := x - bounds.Min.X + 1
+			value := float64(img.GrayAt(x, y).Y) + current[i]
+			level := int(math.Round(value * float64(levels-1) / 255))
+			if level < 0 {
+				level = 0
+			} else if level > levels-1 {
+				level = levels - 1
+			}
+			out.SetColorIndex(x, y, uint8(level))
+
+			if dither {
+				err := value - float64(level*255/(levels-1))
+				current[i+1] += err * 7 / 16
+				next[i-1] += err * 3 / 16
+				next[i] += err * 5 / 16
+				next[i+1] += err * 1 / 16
+			}
+		}
+		current, next = next, current
+		for i := range next {
+			next[i] = 0
+		}
+	}
+	return out
+}
+
+// writePNG draws a display and encodes it at the display's bit depth.
+func writePNG(w io.Writer, d *display, state plannerState, widgets map[string]Widget) error {
+	img := drawDisplay(d, state, widgets)
+	settings := d.Profile.Render
+	if settings.Depth == 8 {
+		return png.Encode(w, img)
+	}
+	return png.Encode(w, quantize(img, settings.Depth, settings.Dither != "none"))
+}
+
+// renderOnce implements "planner render": fetch every widget of a profile
+// once and write the PNG to a file, for frames that are fed by cron.
+func renderOnce(config configStruct, args []string) {
+	flags := flag.NewFlagSet("render", flag.ExitOnError)
+	profile := flags.String("profile", defaultDisplay, "display profile to render")
+	out := flags.String("out", "planner.png", "PNG file to write")
+	flags.Parse(args)
+
+	displays := loadDisplays(config)
+	d, ok := displays[*profile]
+	if !ok {
+		log.Fatalf("  FATAL: Unknown display profile %q\n", *profile)
+	}
+	widgets := loadWidgets(config, d.Profile.Layout.widgetNames())
+	store := newStateStore()
+	for _, widget := range widgets {
+		fetchWidget(widget, store)
+	}
+
+	file, err := os.Create(*out)
+	if err != nil {
+		log.Fatalln("  FATAL: Error creating PNG:", err)
+	}
+	err = writePNG(file, d, store.snapshot(), widgetsByName(widgets))
+	if err == nil {
+		err = file.Close()
+	}
+	if err != nil {
+		log.Fatalln("  FATAL: Error writing PNG:", err)
+	}
+	log.Printf("  INFO: Wrote %s\n", *out)
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPlaceRegions(t *testing.T) {
+	type cell struct{ row, col, colSpan int }
+	tests := []struct {
+		name    string
+		columns int
+		regions []layoutRegion
+		want    []cell
+		rows    int
+	}{
+		{
+			name:    "auto placement in reading order",
+			columns: 3,
+			regions: []layoutRegion{{ColumnSpan: 3}, {}, {}},
+			want:    []cell{{1, 1, 3}, {2, 1, 1}, {2, 2, 1}},
+			rows:    2,
+		},
+		{
+			name:    "explicit row and column",
+			columns: 9,
+			regions: []layoutRegion{{Row: 2, Column: 5, ColumnSpan: 5}, {Row: 1, ColumnSpan: 9}},
+			want:    []cell{{2, 5, 5}, {1, 1, 9}},
+			rows:    2,
+		},
+		{
+			name:    "column moves down past taken cells",
+			columns: 4,
+			regions: []layoutRegion{{ColumnSpan: 4}, {Column: 2, ColumnSpan: 2}},
+			want:    []cell{{1, 1, 4}, {2, 2, 2}},
+			rows:    2,
+		},
+		{
+			name:    "span past the edge pulls the column back",
+			columns: 9,
+			regions: []layoutRegion{{Column: 8, ColumnSpan: 3}},
+			want:    []cell{{1, 7, 3}},
+			rows:    1,
+		},
+		{
+			name:    "column beyond the grid",
+			columns: 9,
+			regions: []layoutRegion{{Column: 12}},
+			want:    []cell{{1, 9, 1}},
+			rows:    1,
+		},
+		{
+			name:    "row and column beyond the grid",
+			columns: 4,
+			regions: []layoutRegion{{Row: 2, Column: 6, ColumnSpan: 2}},
+			want:    []cell{{2, 3, 2}},
+			rows:    2,
+		},
+		{
+			name:    "span wider than the grid",
+			columns: 2,
+			regions: []layoutRegion{{ColumnSpan: 5}, {Column: 3, ColumnSpan: 5}},
+			want:    []cell{{1, 1, 2}, {2, 1, 2}},
+			rows:    2,
+		},
+		{
+			name:    "full row falls back to auto placement",
+			columns: 2,
+			regions: []layoutRegion{{Row: 1, ColumnSpan: 2}, {Row: 1}},
+			want:    []cell{{1, 1, 2}, {2, 1, 1}},
+			rows:    2,
+		},
+		{
+			name:    "no columns",
+			columns: 0,
+			regions: []layoutRegion{{ColumnSpan: 2}, {Column: 3}},
+			want:    []cell{{1, 1, 1}, {2, 1, 1}},
+			rows:    2,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			done := make(chan struct{})
+			var placed []placedRegion
+			var rows int
+			go func() {
+				placed, rows = placeRegions(layoutConfig{Columns: test.columns, Regions: test.regions})
+				close(done)
+			}()
+			select {
+			case <-done:
+			case <-time.After(time.Second):
+				t.Fatal("placeRegions did not return")
+			}
+
+			if rows != test.rows {
+				t.Errorf("rows = %d, want %d", rows, test.rows)
+			}
+			if len(placed) != len(test.want) {
+				t.Fatalf("placed %d regions, want %d", len(placed), len(test.want))
+			}
+			for i, want := range test.want {
+				got := cell{placed[i].row, placed[i].col, placed[i].colSpan}
+				if got != want {
+					t.Errorf("region %d at %+v, want %+v", i, got, want)
+				}
+			}
+		})
+	}
+}
@@ -1,38 +1,89 @@
-function getDate() {
-    var months = ["January", "February", "March", "April", "May", "June", "July", "August", "September", "October", "November", "December"];
-    var days = ["Sunday", "Monday", "Tuesday", "Wednesday", "Thursday", "Friday", "Saturday"];
-    var today = new Date();
-    var year = today.getFullYear();
-    var month = months[today.getMonth()];
-    var day = days[today.getDay()];
-    var date = today.getDate();
-    var dateString = day + ", " + month + " " + date + ", " + year;
-    document.getElementById("date").innerHTML = dateString;
+function subscribeToUpdates() {
+    document.addEventListener("DOMContentLoaded", function() {
+        // EventSource reconnects on its own, and the server replays the
+        // latest state of every widget on each (re)connect.
+        var source = new EventSource("/events");
+        source.addEventListener("widget", patchWidget);
+        source.addEventListener("theme", switchTheme);
+        source.addEventListener("play", playAudio);
+    });
 }
 
-function getTime() {
-    //var setInterval;
-    var hour;
-    var period;
-    setInterval(function() {
-        var today = new Date();
-        var hours = today.getHours();
-        var minutes = today.getMinutes();
-        var minute = minutes.toString();
-        if (minute.length < 2) {
-            minute = "0" + minutes;
+// Rotating panels turn whether the page is pushed to or reloaded.
+document.addEventListener("DOMContentLoaded", function() {
+    setInterval(rotateTracks, 1000);
+});
+
+function patchWidget(event) {
+    var update = JSON.parse(event.data);
+    var regions = document.querySelectorAll('[data-widget="' + update.name + '"]');
+    for (var i = 0; i < regions.length; i++) {
+        if (regions[i].innerHTML !== update.html) {
+            regions[i].innerHTML = update.html;
         }
-        var timeString = hours + ":" + minute;
-        document.getElementById("time").innerHTML = timeString;
-    }, 500);
+    }
+}
+
+function switchTheme(event) {
+    var theme = JSON.parse(event.data);
+    if (theme.display !== document.body.getAttribute("data-display")) {
+        return;
+    }
+    var link = document.getElementById("theme");
+    if (link && link.getAttribute("href") !== theme.href) {
+        link.setAttribute("href", theme.href);
+    }
 }
 
-function refreshFromHTML() {
-    var sec = 1000;
-    var min = sec * 60;
-    var hr = min * 60;
+var playing = null;
+var queuedClips = [];
+
+// playAudio plays a widget's audio when the server announces it, after any
+// clip still playing. Browsers only allow this once the page has been
+// interacted with, unless started with autoplay allowed (Chromium:
+// --autoplay-policy=no-user-gesture-required).
+function playAudio(event) {
+    var play = JSON.parse(event.data);
+    var clips = document.querySelectorAll('[data-widget="' + play.widget + '"] audio');
+    var clip = null;
+    for (var i = 0; i < clips.length; i++) {
+        if (clips[i].getAttribute("src") === play.src) {
+            clip = clips[i];
+        }
+    }
+    if (!clip) {
+        return;
+    }
+    if (playing && !playing.paused && !playing.ended) {
+        queuedClips.push(clip);
+        return;
+    }
+    playClip(clip);
+}
 
-    setInterval(function() {
-        location.reload(false);
-    }, 300000);
-}
\ No newline at end of file
+function playClip(clip) {
+    playing = clip;
+    clip.onended = function() {
+        var next = queuedClips.shift();
+        playing = null;
+        if (next) {
+            playClip(next);
+        }
+    };
+    clip.play();
+}
+
+// rotateTracks shows one word of the day track at a time in rotating
+// panels. The track is picked from the clock so every display agrees and a
+// re-rendered panel carries on where it was.
+function rotateTracks() {
+    var panels = document.querySelectorAll(".wotdTracks.rotate");
+    for (var i = 0; i < panels.length; i++) {
+        var tracks = panels[i].querySelectorAll(".wotdTrack");
+        var seconds = parseInt(panels[i].getAttribute("data-seconds"), 10) || 20;
+        var current = Math.floor(Date.now() / 1000 / seconds) % tracks.length;
+        for (var j = 0; j < tracks.length; j++) {
+            tracks[j].classList.toggle("current", j === current);
+        }
+    }
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"strings"
+)
+
+// layoutConfig describes the page as a grid of regions, each showing one
+// widget. Background names a widget rendered behind the grid, such as the
+// photos widget.
+type layoutConfig struct {
+	Columns    int
+	Rows       int
+	Gap        string
+	Background string
+	Regions    []layoutRegion
+}
+
+// layoutRegion places a widget on the grid. Column and Row are 1-based;
+// zero lets the browser place the region in the next free cell. Style is
+// extra CSS applied to the region.
+type layoutRegion struct {
+	Widget     string
+	Column     int
+	Row        int
+	ColumnSpan int
+	RowSpan    int
+	Style      string
+}
+
+// defaultLayout reproduces the original screen: the clock and weather across
+// the top, the word of the day bottom-left and the calendar bottom-right.
+func defaultLayout() layoutConfig {
+	return layoutConfig{
+		Columns:    9,
+		Gap:        "10px",
+		Background: "photos",
+		Regions: []layoutRegion{
+			{Widget: "clock", Row: 1, ColumnSpan: 9},
+			{Widget: "weather", Row: 2, ColumnSpan: 9},
+			{Widget: "wotd", Row: 3, Column: 1, ColumnSpan: 4},
+			{Widget: "calendar", Row: 3, Column: 5, ColumnSpan: 5, RowSpan: 2},
+			{Widget: "wotdRecap", Row: 4, Column: 1, ColumnSpan: 4},
+		},
+	}
+}
+
+// widgetNames lists every widget the layout uses, each once.
+func (l layoutConfig) widgetNames() []string {
+	var names []string
+	seen := make(map[string]bool)
+	add := func(name string) {
+		if name != "" && !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+
+	add(l.Background)
+	for _, region := range l.Regions {
+		add(region.Widget)
+	}
+	return names
+}
+
+// GridStyle is the CSS for the grid container.
+func (l layoutConfig) GridStyle() template.CSS {
+	columns := l.Columns
+	if columns <= 0 {
+		columns = 1
+	}
+	style := fmt.Sprintf("grid-template-columns: repeat(%d, 1fr);", columns)
+	if l.Rows > 0 {
+		style += fmt.Sprintf(" grid-template-rows: repeat(%d, auto);", l.Rows)
+	}
+	if l.Gap != "" {
+		style += " gap: " + l.Gap + ";"
+	}
+	return template.CSS(style)
+}
+
+// CSS positions the region on the grid and appends its own style.
+// The layout comes from the planner's own config, so it is trusted.
+func (r layoutRegion) CSS() template.CSS {
+	style := "grid-column: " + gridLine(r.Column, r.ColumnSpan) + "; grid-row: " + gridLine(r.Row, r.RowSpan) + ";"
+	if r.Style != "" {
+		style += " " + strings.TrimSpace(r.Style)
+	}
+	return template.CSS(style)
+}
+
+func gridLine(start int, span int) string {
+	if span <= 0 {
+		span = 1
+	}
+	if start <= 0 {
+		return fmt.Sprintf("auto / span %d", span)
+	}
+	return fmt.Sprintf("%d / span %d", start, span)
+}
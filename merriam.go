@@ -0,0 +1,407 @@
+package main
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+)
+
+// mwFeed is the Merriam-Webster word of the day RSS feed.
+type mwFeed struct {
+	Channel struct {
+		Items []struct {
+			Title       string `xml:"title"`
+			Description string `xml:"description"`
+		} `xml:"item"`
+	} `xml:"channel"`
+}
+
+//Define structure to receive WOTD from XML
+type entryList struct {
+	XMLName xml.Name  `xml:"entry_list"`
+	Text    string    `xml:",chardata"`
+	Version string    `xml:"version,attr"`
+	Entries []mwEntry `xml:"entry"`
+}
+
+// mwEntry is one entry of the dictionary, a headword or one of its
+// homographs with a single part of speech.
+type mwEntry struct {
+	Text string `xml:",chardata"`
+	ID   string `xml:"id,attr"`
+	Ew   struct {
+		Text string `xml:",chardata"`
+	} `xml:"ew"`
+	Subj struct {
+		Text string `xml:",chardata"`
+	} `xml:"subj"`
+	Hw struct {
+		Text string `xml:",chardata"`
+	} `xml:"hw"`
+	Sound struct {
+		Text string `xml:",chardata"`
+		Wav  struct {
+			Text string `xml:",chardata"`
+		} `xml:"wav"`
+		Wpr struct {
+			Text string `xml:",chardata"`
+		} `xml:"wpr"`
+	} `xml:"sound"`
+	Pr struct {
+		Text string `xml:",chardata"`
+	} `xml:"pr"`
+	Fl struct {
+		Text string `xml:",chardata"`
+	} `xml:"fl"`
+	In []struct {
+		Text  string `xml:",chardata"`
+		Inner string `xml:",innerxml"`
+		If    struct {
+			Text string `xml:",chardata"`
+		} `xml:"if"`
+	} `xml:"in"`
+	Et struct {
+		Text  string `xml:",chardata"`
+		Inner string `xml:",innerxml"`
+		It    []struct {
+			Text string `xml:",chardata"`
+		} `xml:"it"`
+	} `xml:"et"`
+	Def struct {
+		Text  string `xml:",chardata"`
+		Inner string `xml:",innerxml"`
+		Vt    struct {
+			Text string `xml:",chardata"`
+		} `xml:"vt"`
+		Date struct {
+			Text string `xml:",chardata"`
+		} `xml:"date"`
+		Sn []struct {
+			Text string `xml:",chardata"`
+		} `xml:"sn"`
+		Dt []struct {
+			Text string `xml:",chardata"`
+			Sx   struct {
+				Text string `xml:",chardata"`
+				Sxn  struct {
+					Text string `xml:",chardata"`
+				} `xml:"sxn"`
+			} `xml:"sx"`
+			Vi struct {
+				Text string `xml:",chardata"`
+				It   struct {
+					Text string `xml:",chardata"`
+				} `xml:"it"`
+			} `xml:"vi"`
+		} `xml:"dt"`
+	} `xml:"def"`
+	Uro []struct {
+		Text string `xml:",chardata"`
+		Ure  struct {
+			Text string `xml:",chardata"`
+		} `xml:"ure"`
+		Sound struct {
+			Text string `xml:",chardata"`
+			Wav  struct {
+				Text string `xml:",chardata"`
+			} `xml:"wav"`
+			Wpr struct {
+				Text string `xml:",chardata"`
+			} `xml:"wpr"`
+		} `xml:"sound"`
+		Pr struct {
+			Text string `xml:",chardata"`
+		} `xml:"pr"`
+		Fl struct {
+			Text string `xml:",chardata"`
+		} `xml:"fl"`
+	} `xml:"uro"`
+}
+
+// mwFeedSource reads the word of the day straight from the Merriam-Webster
+// feed. It needs no key, but the feed only carries a short explanation of
+// the word rather than its dictionary definitions.
+type mwFeedSource struct {
+	rss string
+}
+
+func (s *mwFeedSource) Name() string { return "merriam-webster-feed" }
+
+func (s *mwFeedSource) Health() error { return nil }
+
+func (s *mwFeedSource) Word() (wotdType, error) {
+	title, description, err := mwFeedItem(s.rss)
+	if err != nil {
+		return wotdType{}, err
+	}
+
+	// The description starts with "word • \pronunciation\ • part of speech"
+	// and goes on with paragraphs about the word.
+	wotdInfo := wotdType{Word: title}
+	paragraphs := htmlParagraphs(description)
+	if len(paragraphs) > 0 {
+		parts := strings.Split(paragraphs[0], "•")
+		if len(parts) == 3 {
+			wotdInfo.Pronounce = strings.Trim(strings.TrimSpace(parts[1]), `\`)
+			wotdInfo.POS = strings.TrimSpace(parts[2])
+			paragraphs = paragraphs[1:]
+		}
+	}
+	if len(paragraphs) > 0 {
+		wotdInfo.Defs = []string{paragraphs[0]}
+	}
+	return wotdInfo, nil
+}
+
+// mwSource looks up the feed's word in a Merriam-Webster dictionary API for
+// its full definitions. The API answers in the legacy XML format or the
+// current JSON one, depending on the endpoint; format says which the url
+// is. With a word list, the word is the list's word of the day instead of
+// the feed's, for a dictionary such as the elementary one whose readers
+// would not know the feed's words.
+type mwSource struct {
+	rss       string
+	url       string
+	key       string
+	format    string // "xml" or "json"
+	words     *localWordSource
+	audioURL  string
+	audioDir  string
+	maxSenses int
+}
+
+// mwFormats are the API formats with their endpoint, for a dictionary
+// named by mwDictionaries, and the base of their pronunciation clips.
+var mwFormats = map[string]struct{ url, audioURL string }{
+	"xml":  {"https://www.dictionaryapi.com/api/v1/references/%s/xml/", "https://media.merriam-webster.com/soundc11/"},
+	"json": {"https://www.dictionaryapi.com/api/v3/references/%s/json/", "https://media.merriam-webster.com/audio/prons/en/us/mp3/"},
+}
+
+// mwDictionaries are the dictionaries a source can look words up in, each
+// with a key of its own.
+var mwDictionaries = map[string]string{
+	"collegiate":   "collegiate",
+	"intermediate": "sd3",
+	"elementary":   "sd2",
+}
+
+// mwWord is one dictionary entry as either API format describes it.
+type mwWord struct {
+	Word       string // headword without syllable breaks or homograph number
+	Pronounce  string
+	POS        string
+	FirstUse   string
+	Senses     []wotdSense
+	Etymology  string
+	Forms      []string
+	Related    []relatedWord
+	Sound      string // clip file name, such as "transp05.wav"
+	Art        string // illustration URL
+	ArtCaption string
+}
+
+func (s *mwSource) Name() string { return "merriam-webster" }
+
+func (s *mwSource) Health() error {
+	if s.key == "" {
+		return errors.New("no mwKEY configured")
+	}
+	return nil
+}
+
+func (s *mwSource) Word() (wotdType, error) {
+	if s.key == "" {
+		return wotdType{}, errors.New("no mwKEY configured")
+	}
+	var word string
+	var err error
+	if s.words != nil {
+		var listed wotdType
+		listed, err = s.words.Word()
+		word = listed.Word
+	} else {
+		word, _, err = mwFeedItem(s.rss)
+	}
+	if err != nil {
+		return wotdType{}, err
+	}
+
+	wotdURL := s.url + url.PathEscape(word) + "?key=" + url.QueryEscape(s.key)
+	dataBYTES, err := httpGetBytes(wotdURL)
+	if err != nil {
+		return wotdType{}, err
+	}
+	var entries []mwWord
+	if s.format == "json" {
+		entries, err = mwJSONEntries(dataBYTES)
+	} else {
+		entries, err = mwXMLEntries(dataBYTES)
+	}
+	if err != nil {
+		return wotdType{}, fmt.Errorf("reading definition of %q: %v", word, err)
+	}
+
+	entries = mwHeadwordEntries(entries, word)
+	if len(entries) == 0 {
+		return wotdType{}, fmt.Errorf("no dictionary entry for %q", word)
+	}
+	first := entries[0]
+
+	var wotdInfo wotdType
+
+	wotdInfo.Word = first.Word
+	wotdInfo.Pronounce = first.Pronounce
+
+	// Homographs with the same part of speech share a group.
+	var parts []string
+	groups := make(map[string]int)
+	for _, entry := range entries {
+		i, ok := groups[entry.POS]
+		if !ok {
+			i = len(wotdInfo.Entries)
+			groups[entry.POS] = i
+			wotdInfo.Entries = append(wotdInfo.Entries, wotdEntry{POS: entry.POS, Pronounce: entry.Pronounce, FirstUse: entry.FirstUse})
+			if entry.POS != "" {
+				parts = append(parts, entry.POS)
+			}
+		}
+		wotdInfo.Entries[i].Senses = append(wotdInfo.Entries[i].Senses, entry.Senses...)
+	}
+	wotdInfo.POS = strings.Join(parts, ", ")
+
+	// Past the cap, senses and then whole groups are left for "more senses".
+	kept := 0
+	groupsShown := wotdInfo.Entries[:0]
+	for _, group := range wotdInfo.Entries {
+		if s.maxSenses > 0 && kept+len(group.Senses) > s.maxSenses {
+			wotdInfo.MoreSenses += kept + len(group.Senses) - s.maxSenses
+			group.Senses = group.Senses[:s.maxSenses-kept]
+		}
+		if len(group.Senses) == 0 {
+			continue
+		}
+		kept += len(group.Senses)
+		for _, sense := range group.Senses {
+			wotdInfo.Defs = append(wotdInfo.Defs, sense.Text)
+		}
+		groupsShown = append(groupsShown, group)
+	}
+	wotdInfo.Entries = groupsShown
+	if wotdInfo.MoreSenses > 0 {
+		wotdInfo.MoreURL = mwLinkURL + url.PathEscape(wotdInfo.Word)
+	}
+
+	for _, entry := range entries {
+		if wotdInfo.Etymology == "" {
+			wotdInfo.Etymology = entry.Etymology
+		}
+		if wotdInfo.Art == "" {
+			wotdInfo.Art, wotdInfo.ArtCaption = entry.Art, entry.ArtCaption
+		}
+		wotdInfo.Forms = append(wotdInfo.Forms, entry.Forms...)
+		wotdInfo.Related = append(wotdInfo.Related, entry.Related...)
+	}
+
+	// A missing clip only loses the play button.
+	if first.Sound != "" {
+		wotdInfo.Audio, err = cacheAudio(s.audioDir, mwAudioURL(s.audioURL, first.Sound), first.Sound)
+		if err != nil {
+			log.Printf("  INFO: Error caching pronunciation of %s: %v\n", word, err)
+		}
+	}
+	return wotdInfo, nil
+}
+
+// mwXMLEntries reads the entries of a legacy XML API response.
+func mwXMLEntries(data []byte) ([]mwWord, error) {
+	var def1 entryList
+	err := xml.Unmarshal(data, &def1)
+	if err != nil {
+		return nil, err
+	}
+
+	var words []mwWord
+	for _, entry := range def1.Entries {
+		word := mwWord{
+			Word:      mwEntryWord(entry),
+			Pronounce: entry.Pr.Text,
+			POS:       strings.TrimSpace(entry.Fl.Text),
+			Etymology: htmlText(entry.Et.Inner),
+			Sound:     strings.TrimSpace(entry.Sound.Wav.Text),
+		}
+		word.Senses, word.FirstUse, err = mwDefinition(entry.Def.Inner)
+		if err != nil {
+			return nil, fmt.Errorf("formatting %q: %v", entry.ID, err)
+		}
+		for _, in := range entry.In {
+			if form := mwHeadword(in.Inner); form != "" {
+				word.Forms = append(word.Forms, form)
+			}
+		}
+		for _, uro := range entry.Uro {
+			word.Related = append(word.Related, relatedWord{
+				Word:      mwHeadword(uro.Ure.Text),
+				Pronounce: uro.Pr.Text,
+				POS:       uro.Fl.Text,
+			})
+		}
+		words = append(words, word)
+	}
+	return words, nil
+}
+
+// mwHeadwordEntries returns the entries for word itself, such as
+// "transpire[1]" and "transpire[2]", leaving out the neighbouring words the
+// dictionary also returns. When none match, all entries are kept.
+func mwHeadwordEntries(entries []mwWord, word string) []mwWord {
+	var matching []mwWord
+	for _, entry := range entries {
+		if strings.EqualFold(entry.Word, word) {
+			matching = append(matching, entry)
+		}
+	}
+	if len(matching) == 0 {
+		return entries
+	}
+	return matching
+}
+
+// mwEntryWord is an entry's headword without the homograph number that
+// its ID carries, such as the "[1]" of "transpire[1]".
+func mwEntryWord(entry mwEntry) string {
+	if word := mwHeadword(entry.Hw.Text); word != "" {
+		return word
+	}
+	id := entry.ID
+	if i := strings.Index(id, "["); i >= 0 {
+		id = id[:i]
+	}
+	return strings.TrimSpace(id)
+}
+
+// mwHeadword is the text of a headword or inflection without the "*"
+// marking its syllable breaks.
+func mwHeadword(markup string) string {
+	return strings.Replace(htmlText(markup), "*", "", -1)
+}
+
+// mwFeedItem returns the word and description of the feed's latest item.
+func mwFeedItem(rss string) (string, string, error) {
+	data, err := httpGetBytes(rss)
+	if err != nil {
+		return "", "", err
+	}
+	var feed mwFeed
+	err = xml.Unmarshal(data, &feed)
+	if err != nil {
+		return "", "", fmt.Errorf("unmarshaling word of the day feed: %v", err)
+	}
+	if len(feed.Channel.Items) == 0 || strings.TrimSpace(feed.Channel.Items[0].Title) == "" {
+		return "", "", errors.New("word of the day feed has no items")
+	}
+	item := feed.Channel.Items[0]
+	return strings.TrimSpace(item.Title), item.Description, nil
+}
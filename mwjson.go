@@ -0,0 +1,300 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// mwJSONEntry is one entry of a response from the JSON Collegiate API.
+type mwJSONEntry struct {
+	Meta struct {
+		ID string `json:"id"`
+	} `json:"meta"`
+	Hwi struct {
+		Hw  string                `json:"hw"`
+		Prs []mwJSONPronunciation `json:"prs"`
+	} `json:"hwi"`
+	Fl  string `json:"fl"`
+	Ins []struct {
+		Il string `json:"il"`
+		If string `json:"if"`
+	} `json:"ins"`
+	Def []struct {
+		Vd   string                `json:"vd"`
+		Sseq [][][]json.RawMessage `json:"sseq"`
+	} `json:"def"`
+	Uros []struct {
+		Ure string                `json:"ure"`
+		Prs []mwJSONPronunciation `json:"prs"`
+		Fl  string                `json:"fl"`
+	} `json:"uros"`
+	Et       [][]json.RawMessage `json:"et"`
+	Date     string              `json:"date"`
+	Shortdef []string            `json:"shortdef"`
+	Art      struct {
+		Artid string `json:"artid"`
+		Capt  string `json:"capt"`
+	} `json:"art"`
+}
+
+type mwJSONPronunciation struct {
+	Mw    string `json:"mw"`
+	Sound struct {
+		Audio string `json:"audio"`
+	} `json:"sound"`
+}
+
+type mwJSONSense struct {
+	Sn string              `json:"sn"`
+	Dt [][]json.RawMessage `json:"dt"`
+}
+
+// mwArtURL is where the illustrations named by an entry's art ID are.
+const mwArtURL = "https://www.merriam-webster.com/assets/mw/static/art/dict/"
+
+// mwJSONEntries reads the entries of a JSON API response. A word the
+// dictionary does not know is answered with a list of suggestions instead.
+func mwJSONEntries(data []byte) ([]mwWord, error) {
+	var raw []json.RawMessage
+	err := json.Unmarshal(data, &raw)
+	if err != nil {
+		return nil, err
+	}
+	var suggestions []string
+	if len(raw) > 0 && json.Unmarshal(raw[0], new(string)) == nil {
+		json.Unmarshal(data, &suggestions)
+		return nil, fmt.Errorf("not in the dictionary, did you mean %s?", strings.Join(suggestions, ", "))
+	}
+
+	var words []mwWord
+	for _, item := range raw {
+		var entry mwJSONEntry
+		err = json.Unmarshal(item, &entry)
+		if err != nil {
+			return nil, err
+		}
+		word := mwWord{
+			Word:     strings.Replace(entry.Hwi.Hw, "*", "", -1),
+			POS:      entry.Fl,
+			FirstUse: mwJSONText(entry.Date),
+		}
+		if word.Word == "" {
+			word.Word = strings.Split(entry.Meta.ID, ":")[0]
+		}
+		if len(entry.Hwi.Prs) > 0 {
+			word.Pronounce = entry.Hwi.Prs[0].Mw
+			if audio := entry.Hwi.Prs[0].Sound.Audio; audio != "" {
+				word.Sound = audio + ".mp3"
+			}
+		}
+
+		for _, def := range entry.Def {
+			label := def.Vd
+			for _, sequence := range def.Sseq {
+				word.Senses = appendMWSenses(word.Senses, sequence, &label, "")
+			}
+		}
+		if len(word.Senses) == 0 {
+			for _, def := range entry.Shortdef {
+				var f mwFormatter
+				f.write(def)
+				if sense, ok := f.sense("", ""); ok {
+					word.Senses = append(word.Senses, sense)
+				}
+			}
+		}
+
+		for _, et := range entry.Et {
+			var kind, text string
+			if len(et) == 2 && json.Unmarshal(et[0], &kind) == nil && kind == "text" && json.Unmarshal(et[1], &text) == nil {
+				word.Etymology = strings.TrimSpace(word.Etymology + " " + mwJSONText(text))
+			}
+		}
+		for _, in := range entry.Ins {
+			form := strings.Replace(in.If, "*", "", -1)
+			if in.Il != "" {
+				form = in.Il + " " + form
+			}
+			word.Forms = append(word.Forms, form)
+		}
+		for _, uro := range entry.Uros {
+			related := relatedWord{Word: strings.Replace(uro.Ure, "*", "", -1), POS: uro.Fl}
+			if len(uro.Prs) > 0 {
+				related.Pronounce = uro.Prs[0].Mw
+			}
+			word.Related = append(word.Related, related)
+		}
+		if entry.Art.Artid != "" {
+			word.Art = mwArtURL + entry.Art.Artid + ".gif"
+			word.ArtCaption = mwJSONText(entry.Art.Capt)
+		}
+		words = append(words, word)
+	}
+	if len(words) == 0 {
+		return nil, errors.New("no entries")
+	}
+	return words, nil
+}
+
+// appendMWSenses appends the senses of a sense sequence: pairs such as
+// ["sense", {...}], ["sen", {...}] whose number heads the senses after it,
+// ["bs", {"sense": {...}}] and ["pseq", [...]] holding more pairs. A verb
+// type label is given to the first sense only.
+func appendMWSenses(senses []wotdSense, sequence [][]json.RawMessage, label *string, prefix string) []wotdSense {
+	for _, pair := range sequence {
+		var kind string
+		if len(pair) != 2 || json.Unmarshal(pair[0], &kind) != nil {
+			continue
+		}
+		var sense mwJSONSense
+		switch kind {
+		case "pseq":
+			var inner [][]json.RawMessage
+			if json.Unmarshal(pair[1], &inner) == nil {
+				senses = appendMWSenses(senses, inner, label, prefix)
+			}
+			continue
+		case "sen":
+			if json.Unmarshal(pair[1], &sense) == nil {
+				prefix = sense.Sn
+			}
+			continue
+		case "bs":
+			var bs struct {
+				Sense mwJSONSense `json:"sense"`
+			}
+			if json.Unmarshal(pair[1], &bs) != nil {
+				continue
+			}
+			sense = bs.Sense
+		case "sense":
+			if json.Unmarshal(pair[1], &sense) != nil {
+				continue
+			}
+		default:
+			continue
+		}
+
+		number := strings.TrimSpace(prefix + " " + sense.Sn)
+		prefix = ""
+		var f mwFormatter
+		for _, dt := range sense.Dt {
+			f.definingText(dt)
+		}
+		if s, ok := f.sense(number, *label); ok {
+			senses = append(senses, s)
+			*label = ""
+		}
+	}
+	return senses
+}
+
+// definingText formats one ["text", "..."] or ["vis", [{"t": "..."}]]
+// element of a sense. Usage notes and the like are left out.
+func (f *mwFormatter) definingText(dt []json.RawMessage) {
+	var kind string
+	if len(dt) != 2 || json.Unmarshal(dt[0], &kind) != nil {
+		return
+	}
+	switch kind {
+	case "text":
+		var text string
+		if json.Unmarshal(dt[1], &text) == nil {
+			f.markup(text)
+		}
+	case "vis":
+		var examples []struct {
+			T string `json:"t"`
+		}
+		if json.Unmarshal(dt[1], &examples) != nil {
+			return
+		}
+		for _, example := range examples {
+			f.html.WriteString(` <span class="vi">&lt;`)
+			f.text.WriteString(" <")
+			f.markup(example.T)
+			f.html.WriteString(`&gt;</span>`)
+			f.text.WriteString(">")
+		}
+	}
+}
+
+// mwJSONTags are the JSON API's formatting tokens that become HTML tags.
+var mwJSONTags = map[string]string{
+	"b": "<b>", "/b": "</b>",
+	"it": "<i>", "/it": "</i>",
+	"wi": "<i>", "/wi": "</i>",
+	"qword": "<i>", "/qword": "</i>",
+	"parahw": "<b>", "/parahw": "</b>",
+	"phrase": "<b><i>", "/phrase": "</i></b>",
+	"sc": `<span class="sc">`, "/sc": "</span>",
+	"inf": "<sub>", "/inf": "</sub>",
+	"sup": "<sup>", "/sup": "</sup>",
+}
+
+// mwJSONWords are the tokens that stand for text.
+var mwJSONWords = map[string]string{
+	"bc":      ":",
+	"ldquo":   "“",
+	"rdquo":   "”",
+	"p_br":    " ",
+	"gloss":   "[",
+	"/gloss":  "]",
+	"dx":      "— ",
+	"dx_ety":  "— ",
+	"dx_def":  "(",
+	"/dx_def": ")",
+	"ma":      "— more at ",
+}
+
+// markup formats text in the JSON API's markup, where tokens in braces,
+// such as {it}word{/it} or {sx|word||2}, format the text between them.
+// Tokens it does not know, like the date sense {ds||1||}, are dropped.
+func (f *mwFormatter) markup(text string) {
+	for {
+		open := strings.Index(text, "{")
+		if open < 0 {
+			f.write(text)
+			return
+		}
+		closing := strings.Index(text[open:], "}")
+		if closing < 0 {
+			f.write(text)
+			return
+		}
+		f.write(text[:open])
+		token := text[open+1 : open+closing]
+		text = text[open+closing+1:]
+
+		fields := strings.Split(token, "|")
+		field := func(i int) string {
+			if i < len(fields) {
+				return fields[i]
+			}
+			return ""
+		}
+		switch name := fields[0]; {
+		case mwJSONTags[name] != "":
+			f.html.WriteString(mwJSONTags[name])
+		case mwJSONWords[name] != "":
+			f.write(mwJSONWords[name])
+		case name == "sx" || name == "dxt":
+			f.link(field(1), field(3))
+		case name == "a_link" || name == "d_link" || name == "et_link" || name == "mat":
+			f.link(field(1), "")
+		case name == "i_link":
+			f.html.WriteString("<i>")
+			f.write(field(1))
+			f.html.WriteString("</i>")
+		}
+	}
+}
+
+// mwJSONText is the plain text of JSON API markup.
+func mwJSONText(text string) string {
+	var f mwFormatter
+	f.markup(text)
+	return strings.Join(strings.Fields(f.text.String()), " ")
+}
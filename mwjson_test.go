@@ -0,0 +1,207 @@
+package main
+
+import (
+	"encoding/json"
+	"html/template"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMWJSONEntries(t *testing.T) {
+	data, err := ioutil.ReadFile("testdata/mw-transpire.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, err := mwJSONEntries(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("got %d entries, want 3", len(entries))
+	}
+
+	word := entries[0]
+	if word.Word != "transpire" || word.POS != "verb" || word.FirstUse != "1597" || word.Sound != "transp05.mp3" {
+		t.Errorf("word = %q, POS %q, first use %q, sound %q", word.Word, word.POS, word.FirstUse, word.Sound)
+	}
+	if word.Etymology != "Middle French transpirer, from Medieval Latin transpirare" {
+		t.Errorf("etymology = %q", word.Etymology)
+	}
+	if want := []string{"transpired", "transpiring"}; !reflect.DeepEqual(word.Forms, want) {
+		t.Errorf("forms = %q, want %q", word.Forms, want)
+	}
+	if len(word.Related) != 1 || word.Related[0].Word != "transpiration" {
+		t.Errorf("related = %+v", word.Related)
+	}
+	if word.Art != mwArtURL+"transpire.gif" || word.ArtCaption != "a leaf transpiring" {
+		t.Errorf("art = %q, caption %q", word.Art, word.ArtCaption)
+	}
+
+	var numbers []string
+	for _, sense := range word.Senses {
+		numbers = append(numbers, sense.Number)
+	}
+	if want := []string{"1", "2 a", "b", "3"}; !reflect.DeepEqual(numbers, want) {
+		t.Errorf("sense numbers = %q, want %q", numbers, want)
+	}
+	if len(word.Senses) == 4 {
+		if word.Senses[0].Label != "intransitive verb" || word.Senses[1].Label != "" {
+			t.Errorf("labels = %q, %q", word.Senses[0].Label, word.Senses[1].Label)
+		}
+		want := template.HTML(`to pass off or give passage to (a fluid) through pores <span class="vi">&lt;plants <i>transpire</i> water &amp; gases&gt;</span>`)
+		if word.Senses[0].HTML != want {
+			t.Errorf("first sense = %q, want %q", word.Senses[0].HTML, want)
+		}
+	}
+
+	// Entries without a def fall back to their short definitions.
+	if len(entries[1].Senses) != 1 || entries[1].Senses[0].Text != "an invented noun sense" {
+		t.Errorf("second entry senses = %+v", entries[1].Senses)
+	}
+}
+
+func TestMWJSONSuggestions(t *testing.T) {
+	data, err := ioutil.ReadFile("testdata/mw-suggestions.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, err := mwJSONEntries(data)
+	if err == nil {
+		t.Fatalf("got %d entries, want an error", len(entries))
+	}
+	if !strings.Contains(err.Error(), "did you mean transpiring, transpired?") {
+		t.Errorf("error = %v", err)
+	}
+
+	_, err = mwJSONEntries([]byte(`[]`))
+	if err == nil {
+		t.Error("no error for an empty response")
+	}
+}
+
+func TestMWMarkup(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		html string
+		text string
+	}{
+		{
+			name: "plain text is escaped",
+			in:   "<b>bold</b> & more",
+			html: "&lt;b&gt;bold&lt;/b&gt; &amp; more",
+			text: "<b>bold</b> & more",
+		},
+		{
+			name: "formatting tags",
+			in:   "{bc}to pass {it}off{/it} {ldquo}in{rdquo} {sc}vapor{/sc}",
+			html: `:to pass <i>off</i> “in” <span class="sc">vapor</span>`,
+			text: ":to pass off “in” vapor",
+		},
+		{
+			name: "synonymous cross-reference with a sense",
+			in:   "{sx|develop||2}",
+			html: `<a class="xref" href="https://www.merriam-webster.com/dictionary/develop">develop</a> 2`,
+			text: "develop 2",
+		},
+		{
+			name: "cross-reference without a sense",
+			in:   "{sx|happen||}",
+			html: `<a class="xref" href="https://www.merriam-webster.com/dictionary/happen">happen</a>`,
+			text: "happen",
+		},
+		{
+			name: "link to a phrase is escaped",
+			in:   "{a_link|take place}",
+			html: `<a class="xref" href="https://www.merriam-webster.com/dictionary/take%20place">take place</a>`,
+			text: "take place",
+		},
+		{
+			name: "unknown tokens are dropped",
+			in:   "1597{ds||1||}",
+			html: "1597",
+			text: "1597",
+		},
+		{
+			name: "unclosed brace is kept as text",
+			in:   "{it}word{/it} {b unclosed",
+			html: "<i>word</i> {b unclosed",
+			text: "word {b unclosed",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var f mwFormatter
+			f.markup(test.in)
+			if got := f.html.String(); got != test.html {
+				t.Errorf("html = %q, want %q", got, test.html)
+			}
+			if got := f.text.String(); got != test.text {
+				t.Errorf("text = %q, want %q", got, test.text)
+			}
+		})
+	}
+}
+
+func TestAppendMWSenses(t *testing.T) {
+	tests := []struct {
+		name     string
+		sequence string
+		label    string
+		numbers  []string
+		labels   []string
+	}{
+		{
+			name:     "sen number heads the next sense only",
+			sequence: `[["sen", {"sn": "2"}], ["sense", {"sn": "a", "dt": [["text", "{bc}one"]]}], ["sense", {"sn": "b", "dt": [["text", "{bc}two"]]}]]`,
+			numbers:  []string{"2 a", "b"},
+			labels:   []string{"", ""},
+		},
+		{
+			name:     "verb type goes on the first sense",
+			sequence: `[["sense", {"sn": "1", "dt": [["text", "{bc}one"]]}], ["sense", {"sn": "2", "dt": [["text", "{bc}two"]]}]]`,
+			label:    "transitive verb",
+			numbers:  []string{"1", "2"},
+			labels:   []string{"transitive verb", ""},
+		},
+		{
+			name:     "binding sense inside a parenthesized sequence",
+			sequence: `[["pseq", [["bs", {"sense": {"sn": "3", "dt": [["text", "{bc}one"]]}}], ["sense", {"sn": "(1)", "dt": [["text", "{bc}two"]]}]]]]`,
+			numbers:  []string{"3", "(1)"},
+			labels:   []string{"", ""},
+		},
+		{
+			name:     "senses without text and unknown pairs are skipped",
+			sequence: `[["sense", {"sn": "1", "dt": [["uns", []]]}], ["sdsense", {}], ["sense", {"sn": "2", "dt": [["text", "{bc}two"]]}]]`,
+			numbers:  []string{"2"},
+			labels:   []string{""},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var sequence [][]json.RawMessage
+			err := json.Unmarshal([]byte(test.sequence), &sequence)
+			if err != nil {
+				t.Fatal(err)
+			}
+			label := test.label
+			senses := appendMWSenses(nil, sequence, &label, "")
+
+			var numbers, labels []string
+			for _, sense := range senses {
+				numbers = append(numbers, sense.Number)
+				labels = append(labels, sense.Label)
+			}
+			if !reflect.DeepEqual(numbers, test.numbers) {
+				t.Errorf("numbers = %q, want %q", numbers, test.numbers)
+			}
+			if !reflect.DeepEqual(labels, test.labels) {
+				t.Errorf("labels = %q, want %q", labels, test.labels)
+			}
+		})
+	}
+}
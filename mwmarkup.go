@@ -0,0 +1,207 @@
+package main
+
+import (
+	"encoding/xml"
+	"html/template"
+	"io"
+	"net/url"
+	"strings"
+	"unicode"
+)
+
+// wotdSense is one numbered sense of a dictionary definition.
+type wotdSense struct {
+	Number string // as printed by the dictionary: "1", "2 a", "b", "(1)"
+	Level  int    // 0 for a numbered sense, 1 for a lettered one, 2 below that
+	Label  string // verb type, such as "transitive verb", heading this sense
+	Text   string // plain text, as kept in wotdType.Defs
+	HTML   template.HTML
+}
+
+// mwLinkURL is where cross-references in definitions link to.
+const mwLinkURL = "https://www.merriam-webster.com/dictionary/"
+
+// mwDefinition formats the inner XML of an entry's <def> element: its senses
+// in order with their numbers and verb types, and the date of first known
+// use. Everything taken from the markup is HTML-escaped.
+func mwDefinition(inner string) ([]wotdSense, string, error) {
+	decoder := xml.NewDecoder(strings.NewReader("<def>" + inner + "</def>"))
+	decoder.Strict = false
+	decoder.Entity = xml.HTMLEntity
+
+	var senses []wotdSense
+	var label, number, firstUse string
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return senses, firstUse, nil
+		}
+		if err != nil {
+			return senses, firstUse, err
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+
+		switch start.Name.Local {
+		case "def":
+			continue
+		case "vt":
+			label, err = mwElementText(decoder)
+		case "date":
+			firstUse, err = mwElementText(decoder)
+		case "sn":
+			number, err = mwElementText(decoder)
+		case "dt":
+			var f mwFormatter
+			err = f.element(decoder, start)
+			if sense, ok := f.sense(number, label); ok {
+				senses = append(senses, sense)
+				label, number = "", ""
+			}
+		default:
+			err = decoder.Skip()
+		}
+		if err != nil {
+			return senses, firstUse, err
+		}
+	}
+}
+
+// senseLevel is how deep a sense number sits in the hierarchy: "1" and
+// "2 a" start a numbered sense, "b" continues with a letter and "(1)"
+// goes one further.
+func senseLevel(number string) int {
+	switch {
+	case number == "":
+		return 0
+	case strings.HasPrefix(number, "("):
+		return 2
+	case unicode.IsDigit(rune(number[0])):
+		return 0
+	}
+	return 1
+}
+
+// mwFormatter writes the markup of a definition as escaped HTML and as
+// plain text side by side.
+type mwFormatter struct {
+	html strings.Builder
+	text strings.Builder
+}
+
+// sense is what has been written as a sense, without the colon that
+// starts every definition. It is false when nothing has been.
+func (f *mwFormatter) sense(number string, label string) (wotdSense, bool) {
+	text := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(f.text.String()), ":"))
+	if text == "" {
+		return wotdSense{}, false
+	}
+	return wotdSense{
+		Number: number,
+		Level:  senseLevel(number),
+		Label:  label,
+		Text:   text,
+		HTML:   template.HTML(strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(f.html.String()), ":"))),
+	}, true
+}
+
+func (f *mwFormatter) write(s string) {
+	f.html.WriteString(template.HTMLEscapeString(s))
+	f.text.WriteString(s)
+}
+
+// element formats the content of start, whose start tag has been read,
+// up to and including its end tag.
+func (f *mwFormatter) element(decoder *xml.Decoder, start xml.StartElement) error {
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+		switch token := token.(type) {
+		case xml.CharData:
+			f.write(string(token))
+		case xml.EndElement:
+			return nil
+		case xml.StartElement:
+			err = f.child(decoder, token)
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (f *mwFormatter) child(decoder *xml.Decoder, start xml.StartElement) error {
+	switch start.Name.Local {
+	case "it":
+		f.html.WriteString("<i>")
+		err := f.element(decoder, start)
+		f.html.WriteString("</i>")
+		return err
+	case "vi":
+		// Verbal illustrations are example sentences.
+		f.html.WriteString(`<span class="vi">&lt;`)
+		f.text.WriteString("<")
+		err := f.element(decoder, start)
+		f.html.WriteString(`&gt;</span>`)
+		f.text.WriteString(">")
+		return err
+	case "sx":
+		return f.crossReference(decoder)
+	case "sn":
+		// A sense number inside a definition, such as "sense 2".
+		number, err := mwElementText(decoder)
+		f.write(number)
+		return err
+	}
+	return f.element(decoder, start)
+}
+
+// crossReference formats <sx>, a synonymous cross-reference, as a link to
+// the word with the referenced sense number (<sxn>) after it.
+func (f *mwFormatter) crossReference(decoder *xml.Decoder) error {
+	var word, sense strings.Builder
+	for depth := 0; depth >= 0; {
+		token, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+		switch token := token.(type) {
+		case xml.CharData:
+			if depth > 0 {
+				sense.Write(token)
+			} else {
+				word.Write(token)
+			}
+		case xml.StartElement:
+			depth++
+		case xml.EndElement:
+			depth--
+		}
+	}
+
+	f.link(strings.TrimSpace(word.String()), strings.TrimSpace(sense.String()))
+	return nil
+}
+
+// link writes a link to word's dictionary page, followed by the number of
+// the sense referred to, if any.
+func (f *mwFormatter) link(word string, sense string) {
+	f.html.WriteString(`<a class="xref" href="` + template.HTMLEscapeString(mwLinkURL+url.PathEscape(word)) + `">`)
+	f.write(word)
+	f.html.WriteString("</a>")
+	if sense != "" {
+		f.write(" " + sense)
+	}
+}
+
+// mwElementText is the text of the element whose start tag has just been
+// read, with any markup inside it dropped.
+func mwElementText(decoder *xml.Decoder) (string, error) {
+	var f mwFormatter
+	err := f.element(decoder, xml.StartElement{})
+	return strings.Join(strings.Fields(f.text.String()), " "), err
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"html/template"
+	"io/ioutil"
+	"reflect"
+	"testing"
+)
+
+func TestMWDefinition(t *testing.T) {
+	tests := []struct {
+		name     string
+		inner    string
+		want     []wotdSense
+		firstUse string
+	}{
+		{
+			name:     "numbers, letters and verb types",
+			inner:    `<vt>intransitive verb</vt><date>1597</date><sn>1</sn><dt>:to pass off</dt><sn>2 a</sn><dt>:to become known</dt><sn>b</sn><dt>:to take place</dt><vt>transitive verb</vt><dt>:to pass off as vapor</dt>`,
+			firstUse: "1597",
+			want: []wotdSense{
+				{Number: "1", Level: 0, Label: "intransitive verb", Text: "to pass off", HTML: "to pass off"},
+				{Number: "2 a", Level: 0, Text: "to become known", HTML: "to become known"},
+				{Number: "b", Level: 1, Text: "to take place", HTML: "to take place"},
+				{Label: "transitive verb", Text: "to pass off as vapor", HTML: "to pass off as vapor"},
+			},
+		},
+		{
+			name:  "cross-reference with a sense number",
+			inner: `<sn>2 a</sn><dt>:to become known :<sx>develop <sxn>2</sxn></sx></dt>`,
+			want: []wotdSense{{
+				Number: "2 a",
+				Text:   "to become known :develop 2",
+				HTML:   `to become known :<a class="xref" href="https://www.merriam-webster.com/dictionary/develop">develop</a> 2`,
+			}},
+		},
+		{
+			name:  "verbal illustration in italics",
+			inner: `<dt>:to pass off <vi>plants <it>transpire</it> water</vi></dt>`,
+			want: []wotdSense{{
+				Text: "to pass off <plants transpire water>",
+				HTML: `to pass off <span class="vi">&lt;plants <i>transpire</i> water&gt;</span>`,
+			}},
+		},
+		{
+			name:  "markup in the text is escaped",
+			inner: `<dt>:to pass &lt;script&gt; off &amp; on</dt>`,
+			want:  []wotdSense{{Text: "to pass <script> off & on", HTML: "to pass &lt;script&gt; off &amp; on"}},
+		},
+		{
+			name:  "empty definitions and unknown elements are skipped",
+			inner: `<sn>1</sn><dt>:</dt><ss>ignored</ss><sn>2</sn><dt>:real</dt>`,
+			want:  []wotdSense{{Number: "2", Text: "real", HTML: "real"}},
+		},
+		{
+			name:  "sense number inside a definition",
+			inner: `<dt>:see <it>transpire</it> sense <sn>2</sn></dt>`,
+			want:  []wotdSense{{Text: "see transpire sense 2", HTML: "see <i>transpire</i> sense 2"}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			senses, firstUse, err := mwDefinition(test.inner)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if firstUse != test.firstUse {
+				t.Errorf("first use = %q, want %q", firstUse, test.firstUse)
+			}
+			if !reflect.DeepEqual(senses, test.want) {
+				t.Errorf("senses =\n%+v\nwant\n%+v", senses, test.want)
+			}
+		})
+	}
+}
+
+func TestSenseLevel(t *testing.T) {
+	tests := map[string]int{"": 0, "1": 0, "2 a": 0, "b": 1, "(1)": 2}
+	for number, want := range tests {
+		if got := senseLevel(number); got != want {
+			t.Errorf("senseLevel(%q) = %d, want %d", number, got, want)
+		}
+	}
+}
+
+func TestMWXMLEntries(t *testing.T) {
+	data, err := ioutil.ReadFile("testdata/mw-transpire.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, err := mwXMLEntries(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 4 {
+		t.Fatalf("got %d entries, want 4", len(entries))
+	}
+
+	words := mwHeadwordEntries(entries, "transpire")
+	if len(words) != 3 {
+		t.Fatalf("got %d entries for transpire, want 3", len(words))
+	}
+	word := words[0]
+	if word.Word != "transpire" || word.POS != "verb" || word.FirstUse != "1597" || word.Sound != "transp05.wav" {
+		t.Errorf("word = %q, POS %q, first use %q, sound %q", word.Word, word.POS, word.FirstUse, word.Sound)
+	}
+	if word.Etymology != "Middle French transpirer, from Medieval Latin transpirare, from Latin trans- + spirare to breathe" {
+		t.Errorf("etymology = %q", word.Etymology)
+	}
+	if want := []string{"transpired; transpiring"}; !reflect.DeepEqual(word.Forms, want) {
+		t.Errorf("forms = %q, want %q", word.Forms, want)
+	}
+	if len(word.Related) != 1 || word.Related[0].Word != "transpiration" || word.Related[0].POS != "noun" {
+		t.Errorf("related = %+v", word.Related)
+	}
+	if len(word.Senses) != 4 {
+		t.Fatalf("got %d senses, want 4", len(word.Senses))
+	}
+	if want := template.HTML("to pass off &lt;script&gt; in the form of a vapor"); word.Senses[3].HTML != want {
+		t.Errorf("last sense = %q, want %q", word.Senses[3].HTML, want)
+	}
+	if words[1].POS != "noun" || words[1].FirstUse != "1640" {
+		t.Errorf("second entry POS %q, first use %q", words[1].POS, words[1].FirstUse)
+	}
+}
@@ -0,0 +1,431 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type onThisDaySettings struct {
+	File       string   // the local dataset, "json/onthisday.json" by default
+	Source     string   // URL or file "planner onthisday import" reads, Wikipedia by default
+	Categories []string // "selected", "events", "births", "deaths" or "holidays"
+	Count      int      // how many to show, 5 by default
+}
+
+// onThisDayEvent is one event, birth, death or holiday of a day. Years
+// before the common era are negative; holidays have none.
+type onThisDayEvent struct {
+	Category string `json:"category"`
+	Year     int    `json:"year,omitempty"`
+	Text     string `json:"text"`
+}
+
+// onThisDayData is the widget's data.
+type onThisDayData struct {
+	Date   string
+	Events []onThisDayEvent
+}
+
+// onThisDayWidget lists a few events, births and holidays of today's date
+// from a local dataset, which "planner onthisday import" fills.
+type onThisDayWidget struct {
+	settings onThisDaySettings
+}
+
+// onThisDaySource is Wikipedia's "on this day" feed, in the household's
+// language. {month} and {day} are filled in with two digits.
+const onThisDaySource = "https://{language}.wikipedia.org/api/rest_v1/feed/onthisday/all/{month}/{day}"
+
+func init() {
+	registerWidget("onThisDay", func() Widget { return &onThisDayWidget{} })
+}
+
+func (w *onThisDayWidget) Name() string { return "onThisDay" }
+
+func (w *onThisDayWidget) Settings() interface{} { return &w.settings }
+
+func (w *onThisDayWidget) Configure(config configStruct) error {
+	if w.settings.File == "" {
+		w.settings.File = "json/onthisday.json"
+	}
+	if w.settings.Source == "" {
+		language := activeLocale.Language
+		if language == "" {
+			language = "en"
+		}
+		w.settings.Source = strings.Replace(onThisDaySource, "{language}", language, 1)
+	}
+	if len(w.settings.Categories) == 0 {
+		w.settings.Categories = []string{"events", "births", "holidays"}
+	}
+	if w.settings.Count <= 0 {
+		w.settings.Count = 5
+	}
+	for _, category := range w.settings.Categories {
+		if onThisDayCategories[category] == "" {
+			return fmt.Errorf("unknown category %q", category)
+		}
+	}
+	return nil
+}
+
+// onThisDayCategories are the categories and what marks them on the panel.
+var onThisDayCategories = map[string]string{
+	"selected": "-",
+	"events":   "-",
+	"births":   "Born",
+	"deaths":   "Died",
+	"holidays": "Holiday",
+}
+
+// Label is what the event is marked with, or nothing for plain events.
+func (e onThisDayEvent) Label() string {
+	if label := onThisDayCategories[e.Category]; label != "-" {
+		return label
+	}
+	return ""
+}
+
+// When is the event's year, such as "1781" or "44 BC".
+func (e onThisDayEvent) When() string {
+	switch {
+	case e.Year < 0:
+		return strconv.Itoa(-e.Year) + " BC"
+	case e.Year > 0:
+		return strconv.Itoa(e.Year)
+	}
+	return ""
+}
+
+// Interval is short so the panel changes soon after midnight; reading the
+// dataset costs nothing.
+func (w *onThisDayWidget) Interval() time.Duration { return time.Hour }
+
+// Fetch picks today's events in the planner's timezone, taking the first
+// of each category in turn so one category cannot fill the panel.
+func (w *onThisDayWidget) Fetch() (interface{}, error) {
+	days, err := loadOnThisDay(w.settings.File)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().In(plannerLocation)
+	today := now.Format("01-02")
+	byCategory := make(map[string][]onThisDayEvent)
+	for _, event := range days[today] {
+		byCategory[event.Category] = append(byCategory[event.Category], event)
+	}
+
+	var events []onThisDayEvent
+	for len(events) < w.settings.Count {
+		added := false
+		for _, category := range w.settings.Categories {
+			if len(byCategory[category]) > 0 && len(events) < w.settings.Count {
+				events = append(events, byCategory[category][0])
+				byCategory[category] = byCategory[category][1:]
+				added = true
+			}
+		}
+		if !added {
+			break
+		}
+	}
+	if len(events) == 0 {
+		return nil, fmt.Errorf("no %s for %s in %s, run planner onthisday import", strings.Join(w.settings.Categories, ", "), today, w.settings.File)
+	}
+
+	order := make(map[string]int)
+	for i, category := range w.settings.Categories {
+		order[category] = i
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		if events[i].Category != events[j].Category {
+			return order[events[i].Category] < order[events[j].Category]
+		}
+		return events[i].Year < events[j].Year
+	})
+	log.Println("  INFO: Finished getOnThisDay()")
+	return onThisDayData{Date: now.Format("2006-01-02"), Events: events}, nil
+}
+
+func (w *onThisDayWidget) Template() string {
+	return `
+<h2>{{t "On This Day"}}</h2>
+<ul id="onThisDay">
+    {{- range .Events}}
+    <li class="{{.Category}}">{{with .When}}<span class="historyYear">{{.}}</span> {{end}}{{with .Label}}<span class="historyLabel">{{t .}}</span> {{end}}{{.Text}}</li>
+    {{- end}}
+</ul>`
+}
+
+// Health reports a dataset that is missing or has nothing for today.
+func (w *onThisDayWidget) Health() error {
+	days, err := loadOnThisDay(w.settings.File)
+	if err != nil {
+		return err
+	}
+	today := time.Now().In(plannerLocation).Format("01-02")
+	if len(days[today]) == 0 {
+		return fmt.Errorf("%s has nothing for %s, run planner onthisday import", w.settings.File, today)
+	}
+	return nil
+}
+
+// loadOnThisDay reads the dataset, which maps "MM-DD" to the day's events.
+func loadOnThisDay(file string) (map[string][]onThisDayEvent, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var days map[string][]onThisDayEvent
+	err = json.Unmarshal(data, &days)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %v", file, err)
+	}
+	return days, nil
+}
+
+func saveOnThisDay(file string, days map[string][]onThisDayEvent) error {
+	data, err := json.MarshalIndent(days, "", "    ")
+	if err != nil {
+		return err
+	}
+	err = ioutil.WriteFile(file+".tmp", data, 0644)
+	if err != nil {
+		return err
+	}
+	return os.Rename(file+".tmp", file)
+}
+
+// onThisDayCommand implements "planner onthisday import": it reads the
+// source for the coming days and stores their events in the dataset,
+// replacing what it had for those days. A source without {month} and {day},
+// such as a saved export or a feed of today's events, is read once.
+func onThisDayCommand(config configStruct, args []string) {
+	if len(args) == 0 || args[0] != "import" {
+		log.Fatalln("  FATAL: Usage: planner onthisday import [--source url|file] [--days n] [--date MM-DD]")
+	}
+	w := loadWidgets(config, []string{"onThisDay"})[0].(*onThisDayWidget)
+	flags := flag.NewFlagSet("onthisday import", flag.ExitOnError)
+	source := flags.String("source", w.settings.Source, "URL or file to import")
+	count := flags.Int("days", 7, "days to import from a dated source, starting today")
+	date := flags.String("date", "", "MM-DD to file an undated source under, today by default")
+	flags.Parse(args[1:])
+
+	days, err := loadOnThisDay(w.settings.File)
+	if os.IsNotExist(err) {
+		days, err = make(map[string][]onThisDayEvent), nil
+	}
+	if err != nil {
+		log.Fatalln("  FATAL: Error reading dataset:", err)
+	}
+
+	now := time.Now().In(plannerLocation)
+	dated := strings.Contains(*source, "{month}") || strings.Contains(*source, "{day}")
+	if !dated {
+		*count = 1
+	}
+	imported := 0
+	for i := 0; i < *count; i++ {
+		day := now.AddDate(0, 0, i)
+		location := strings.NewReplacer("{month}", day.Format("01"), "{day}", day.Format("02")).Replace(*source)
+		key := day.Format("01-02")
+		if !dated && *date != "" {
+			key = *date
+		}
+
+		found, err := importOnThisDay(location, key)
+		if err != nil {
+			log.Fatalf("  FATAL: Error importing %s: %v\n", location, err)
+		}
+		for key, events := range found {
+			days[key] = events
+			imported += len(events)
+			log.Printf("  INFO: Imported %d for %s\n", len(events), key)
+		}
+	}
+
+	err = saveOnThisDay(w.settings.File, days)
+	if err != nil {
+		log.Fatalln("  FATAL: Error saving dataset:", err)
+	}
+	fmt.Printf("imported %d events into %s\n", imported, w.settings.File)
+}
+
+// importOnThisDay reads one URL or file: Wikipedia's JSON, a dataset like
+// the planner's own, a JSON list of events or an RSS or Atom feed. Whatever
+// does not say which day it is for is filed under key.
+func importOnThisDay(location string, key string) (map[string][]onThisDayEvent, error) {
+	var data []byte
+	var err error
+	if strings.HasPrefix(location, "http://") || strings.HasPrefix(location, "https://") {
+		data, err = onThisDayGet(location)
+	} else {
+		data, err = ioutil.ReadFile(location)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	text := strings.TrimSpace(string(data))
+	switch {
+	case strings.HasPrefix(text, "["):
+		var events []onThisDayEvent
+		err = json.Unmarshal(data, &events)
+		return map[string][]onThisDayEvent{key: events}, err
+	case strings.HasPrefix(text, "{"):
+		return onThisDayJSON(data, key)
+	case strings.HasPrefix(text, "<"):
+		events, err := onThisDayFeed(data)
+		return map[string][]onThisDayEvent{key: events}, err
+	}
+	return nil, errors.New("not JSON, RSS or Atom")
+}
+
+// onThisDayGet fetches a URL. Wikimedia asks clients to say who they are.
+func onThisDayGet(url string) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "planner/"+version)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %v", url, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s returned %s", url, resp.Status)
+	}
+	return data, nil
+}
+
+// onThisDayDate is a dataset key.
+var onThisDayDate = regexp.MustCompile(`^\d\d-\d\d$`)
+
+// onThisDayJSON reads Wikipedia's {"events": [{"text": ..., "year": ...}],
+// "births": [...], ...} or a dataset keyed by "MM-DD".
+func onThisDayJSON(data []byte, key string) (map[string][]onThisDayEvent, error) {
+	var raw map[string]json.RawMessage
+	err := json.Unmarshal(data, &raw)
+	if err != nil {
+		return nil, err
+	}
+	for date := range raw {
+		if onThisDayDate.MatchString(date) {
+			var days map[string][]onThisDayEvent
+			err = json.Unmarshal(data, &days)
+			return days, err
+		}
+	}
+
+	var events []onThisDayEvent
+	for category, list := range raw {
+		if onThisDayCategories[category] == "" {
+			continue
+		}
+		var items []struct {
+			Text string `json:"text"`
+			Year int    `json:"year"`
+		}
+		err = json.Unmarshal(list, &items)
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %v", category, err)
+		}
+		for _, item := range items {
+			if text := strings.TrimSpace(item.Text); text != "" {
+				events = append(events, onThisDayEvent{Category: category, Year: item.Year, Text: text})
+			}
+		}
+	}
+	if len(events) == 0 {
+		return nil, errors.New("no events")
+	}
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Category < events[j].Category })
+	return map[string][]onThisDayEvent{key: events}, nil
+}
+
+// onThisDayFeedXML covers both RSS items and Atom entries.
+type onThisDayFeedXML struct {
+	Items []struct {
+		Title       string   `xml:"title"`
+		Description string   `xml:"description"`
+		Categories  []string `xml:"category"`
+	} `xml:"channel>item"`
+	Entries []struct {
+		Title      string `xml:"title"`
+		Summary    string `xml:"summary"`
+		Categories []struct {
+			Term string `xml:"term,attr"`
+		} `xml:"category"`
+	} `xml:"entry"`
+}
+
+// onThisDayYear is the year leading a feed item, as in "1781 – Cornwallis
+// surrenders" or "44 BC: Caesar is assassinated".
+var onThisDayYear = regexp.MustCompile(`^(\d{1,4})(\s*BCE?)?\s*(?:-|–|—|:)\s*(.+)$`)
+
+// onThisDayFeed reads a feed with an item per event. An item is filed under
+// the first of its categories the planner knows, and under events without.
+func onThisDayFeed(data []byte) ([]onThisDayEvent, error) {
+	var feed onThisDayFeedXML
+	err := xml.Unmarshal(data, &feed)
+	if err != nil {
+		return nil, fmt.Errorf("unmarshaling feed: %v", err)
+	}
+
+	var events []onThisDayEvent
+	add := func(title string, text string, categories []string) {
+		event := onThisDayEvent{Category: "events", Text: htmlText(title)}
+		if match := onThisDayYear.FindStringSubmatch(event.Text); match == nil && htmlText(text) != "" {
+			event.Text = htmlText(text)
+		}
+		if match := onThisDayYear.FindStringSubmatch(event.Text); match != nil {
+			event.Year, _ = strconv.Atoi(match[1])
+			if match[2] != "" {
+				event.Year = -event.Year
+			}
+			event.Text = match[3]
+		}
+		for _, category := range categories {
+			category = strings.ToLower(strings.TrimSpace(category))
+			if onThisDayCategories[category] != "" {
+				event.Category = category
+				break
+			}
+		}
+		if event.Text != "" {
+			events = append(events, event)
+		}
+	}
+	for _, item := range feed.Items {
+		add(item.Title, item.Description, item.Categories)
+	}
+	for _, entry := range feed.Entries {
+		var categories []string
+		for _, category := range entry.Categories {
+			categories = append(categories, category.Term)
+		}
+		add(entry.Title, entry.Summary, categories)
+	}
+	if len(events) == 0 {
+		return nil, errors.New("feed has no events")
+	}
+	return events, nil
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// The printable sheet is written as a bare PDF 1.4 file using the standard
+// Helvetica fonts, which every PDF reader provides, so nothing needs to be
+// embedded. Coordinates are in points with the origin at the bottom left.
+
+// paperSizes maps the supported paper names to their size in points.
+var paperSizes = map[string][2]float64{
+	"letter": {612, 792},
+	"a4":     {595.28, 841.89},
+}
+
+// Advance widths of printable ASCII in thousandths of the font size, from
+// the Adobe font metrics.
+var helveticaWidths = [95]int{
+	278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278,
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556,
+	1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778,
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556,
+	333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556,
+	556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584,
+}
+
+var helveticaBoldWidths = [95]int{
+	278, 333, 474, 556, 556, 889, 722, 238, 333, 333, 389, 584, 278, 333, 278, 278,
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 333, 333, 584, 584, 584, 611,
+	975, 722, 722, 722, 722, 667, 611, 778, 722, 278, 556, 722, 611, 833, 722, 778,
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 333, 278, 333, 584, 556,
+	333, 556, 611, 556, 611, 556, 333, 611, 611, 278, 278, 556, 278, 889, 611, 611,
+	611, 611, 389, 556, 333, 611, 556, 778, 556, 556, 500, 389, 280, 389, 584,
+}
+
+// pdfFont is one of the two fonts available on a page.
+type pdfFont struct {
+	resource string
+	widths   *[95]int
+}
+
+var (
+	fontRegular = pdfFont{"F1", &helveticaWidths}
+	fontBold    = pdfFont{"F2", &helveticaBoldWidths}
+)
+
+// pdfText converts s to the fonts' WinAnsi encoding. Latin-1 letters map to
+// themselves; anything else goes through the bitmap font's fallbacks.
+func pdfText(s string) []byte {
+	var b []byte
+	for _, r := range s {
+		switch {
+		case r >= ' ' && r <= '~':
+			b = append(b, byte(r))
+		case r >= 0xa0 && r <= 0xff:
+			b = append(b, byte(r))
+		case r == '‘' || r == '’':
+			b = append(b, 0x91+byte(r-'‘'))
+		case r == '“' || r == '”':
+			b = append(b, 0x93+byte(r-'“'))
+		case r == '–':
+			b = append(b, 0x96)
+		case r == '—':
+			b = append(b, 0x97)
+		default:
+			b = append(b, glyphText(string(r))...)
+		}
+	}
+	return b
+}
+
+// width is the width of s in points at size.
+func (f pdfFont) width(s string, size float64) float64 {
+	total := 0
+	for _, c := range pdfText(s) {
+		if c >= ' ' && c <= '~' {
+			total += f.widths[c-' ']
+		} else {
+			total += 556
+		}
+	}
+	return float64(total) * size / 1000
+}
+
+// wrap breaks s into lines no wider than width points.
+func (f pdfFont) wrap(s string, size float64, width float64) []string {
+	var lines []string
+	var line string
+	for _, word := range strings.Fields(s) {
+		candidate := word
+		if line != "" {
+			candidate = line + " " + word
+		}
+		if line != "" && f.width(candidate, size) > width {
+			lines = append(lines, line)
+			candidate = word
+		}
+		line = candidate
+	}
+	if line != "" {
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// pdfPage collects the drawing operators of a single page.
+type pdfPage struct {
+	width, height float64
+	content       bytes.Buffer
+}
+
+func newPDFPage(paper string) (*pdfPage, error) {
+	if paper == "" {
+		paper = "letter"
+	}
+	size, ok := paperSizes[strings.ToLower(paper)]
+	if !ok {
+		return nil, fmt.Errorf("unknown paper size %q, use letter or a4", paper)
+	}
+	return &pdfPage{width: size[0], height: size[1]}, nil
+}
+
+func (p *pdfPage) text(x, y float64, font pdfFont, size float64, s string) {
+	var escaped bytes.Buffer
+	for _, c := range pdfText(s) {
+		if c == '(' || c == ')' || c == '\\' {
+			escaped.WriteByte('\\')
+		}
+		escaped.WriteByte(c)
+	}
+	fmt.Fprintf(&p.content, "BT /%s %.2f Tf %.2f %.2f Td (%s) Tj ET\n", font.resource, size, x, y, escaped.Bytes())
+}
+
+// rule draws a line of the given thickness and grey level (0 is black).
+func (p *pdfPage) rule(x0, y0, x1, y1, thickness, grey float64) {
+	fmt.Fprintf(&p.content, "%.2f G %.2f w %.2f %.2f m %.2f %.2f l S\n", grey, thickness, x0, y0, x1, y1)
+}
+
+// box strokes a rectangle whose bottom-left corner is at (x, y).
+func (p *pdfPage) box(x, y, w, h, thickness, grey float64) {
+	fmt.Fprintf(&p.content, "%.2f G %.2f w %.2f %.2f %.2f %.2f re S\n", grey, thickness, x, y, w, h)
+}
+
+// writeTo writes the page as a complete one-page PDF document.
+func (p *pdfPage) writeTo(w io.Writer) error {
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.2f %.2f] "+
+			"/Resources << /Font << /F1 4 0 R /F2 5 0 R >> >> /Contents 6 0 R >>", p.width, p.height),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>",
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>",
+		fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", p.content.Len(), p.content.String()),
+	}
+
+	var doc bytes.Buffer
+	doc.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	offsets := make([]int, len(objects))
+	for i, object := range objects {
+		offsets[i] = doc.Len()
+		fmt.Fprintf(&doc, "%d 0 obj\n%s\nendobj\n", i+1, object)
+	}
+	xref := doc.Len()
+	fmt.Fprintf(&doc, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&doc, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&doc, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+
+	_, err := w.Write(doc.Bytes())
+	return err
+}
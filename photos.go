@@ -0,0 +1,86 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"time"
+)
+
+type photosSettings struct {
+	Dir            string
+	ReloadInterval int // minutes
+}
+
+type photosWidget struct {
+	settings photosSettings
+}
+
+func init() {
+	registerWidget("photos", func() Widget { return &photosWidget{} })
+}
+
+func (w *photosWidget) Name() string { return "photos" }
+
+func (w *photosWidget) Settings() interface{} { return &w.settings }
+
+func (w *photosWidget) Configure(config configStruct) error {
+	if w.settings.Dir == "" {
+		w.settings.Dir = config.PhotoDir
+	}
+	if w.settings.ReloadInterval == 0 {
+		w.settings.ReloadInterval = config.PhotoReloadInterval
+	}
+	if w.settings.ReloadInterval <= 0 {
+		w.settings.ReloadInterval = 1
+	}
+	return nil
+}
+
+// photoDir is where /photos/ serves from: the photos widget's directory,
+// which may override the configured one.
+func photoDir(config configStruct, widgets []Widget) string {
+	for _, widget := range widgets {
+		if photos, ok := widget.(*photosWidget); ok {
+			return photos.settings.Dir
+		}
+	}
+	return config.PhotoDir
+}
+
+func (w *photosWidget) Interval() time.Duration {
+	return time.Minute * time.Duration(w.settings.ReloadInterval)
+}
+
+func (w *photosWidget) Fetch() (interface{}, error) {
+	rand.Seed(time.Now().Unix())
+
+	deck, err := ioutil.ReadDir(w.settings.Dir)
+	if err != nil {
+		return nil, err
+	}
+	if len(deck) == 0 {
+		return nil, fmt.Errorf("no photos in %s", w.settings.Dir)
+	}
+
+	index := rand.Intn(len(deck))
+	photo := deck[index].Name()
+	return photo, nil
+}
+
+// The photo is the page background, so the fragment is only a style rule.
+func (w *photosWidget) Template() string {
+	return `<style>html { background-image: url("/photos/{{.}}"); }</style>`
+}
+
+func (w *photosWidget) Health() error {
+	deck, err := ioutil.ReadDir(w.settings.Dir)
+	if err != nil {
+		return err
+	}
+	if len(deck) == 0 {
+		return errors.New("photo directory is empty")
+	}
+	return nil
+}
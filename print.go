@@ -0,0 +1,239 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type printSettings struct {
+	Paper string // "letter" or "a4"
+}
+
+// printSheet lays out the daily sheet from the top of the page down. Once
+// the bottom margin is reached the page is full and the rest is left out.
+type printSheet struct {
+	page   *pdfPage
+	margin float64
+	y      float64
+	full   bool
+}
+
+func (s *printSheet) width() float64 { return s.page.width - 2*s.margin }
+
+// room reports whether a line of size still fits above the bottom margin.
+func (s *printSheet) room(size float64) bool {
+	if s.y-size*1.3 < s.margin {
+		s.full = true
+	}
+	return !s.full
+}
+
+func (s *printSheet) line(font pdfFont, size float64, text string) {
+	if !s.room(size) {
+		return
+	}
+	s.y -= size * 1.3
+	s.page.text(s.margin, s.y, font, size, text)
+}
+
+// paragraph wraps text; when the page fills up part way through, the last
+// line that fits ends in an ellipsis.
+func (s *printSheet) paragraph(font pdfFont, size float64, indent float64, text string) {
+	width := s.width() - indent
+	lines := font.wrap(text, size, width)
+	for i, line := range lines {
+		if !s.room(size) {
+			return
+		}
+		s.y -= size * 1.3
+		if i < len(lines)-1 && !s.room(size) {
+			line = ellipsize(font, size, width, line)
+		}
+		s.page.text(s.margin+indent, s.y, font, size, line)
+	}
+}
+
+// heading starts a section, unless there is no room for a line under it.
+func (s *printSheet) heading(text string) {
+	if s.y-14-16*1.3-9-12*1.3 < s.margin {
+		s.full = true
+	}
+	if s.full {
+		return
+	}
+	s.y -= 14
+	s.line(fontBold, 16, text)
+	s.y -= 5
+	s.page.rule(s.margin, s.y, s.margin+s.width(), s.y, 1, 0)
+	s.y -= 4
+}
+
+// ellipsize drops words off the end of line until it fits width with "..."
+// after it.
+func ellipsize(font pdfFont, size float64, width float64, line string) string {
+	words := strings.Fields(line)
+	for len(words) > 1 && font.width(strings.Join(words, " ")+"...", size) > width {
+		words = words[:len(words)-1]
+	}
+	return strings.Join(words, " ") + "..."
+}
+
+// writeTodayPDF writes the one-page "Today" sheet for the fridge: the date,
+// today's forecast with hourly highlights, any active alerts and the word
+// of the day. Sections whose widget has no data yet are left out.
+func writeTodayPDF(w io.Writer, paper string, state plannerState) error {
+	page, err := newPDFPage(paper)
+	if err != nil {
+		return err
+	}
+	sheet := &printSheet{page: page, margin: 54, y: page.height - 54}
+	t := activeLocale.translate
+	number := func(x float64) string { return activeLocale.formatNumber(x, 0) }
+
+	now := time.Now().In(plannerLocation)
+	sheet.line(fontBold, 28, t("Today"))
+	sheet.y -= 4
+	sheet.line(fontRegular, 14, activeLocale.formatDate(now))
+
+	forecast, ok := state.forecast()
+	if ok && len(forecast.Daily.Data) > 0 {
+		location, err := time.LoadLocation(forecast.Timezone)
+		if err != nil {
+			location = plannerLocation
+		}
+		today := forecast.Daily.Data[0]
+		units := forecast.Units
+
+		sheet.heading(t("Weather"))
+		sheet.paragraph(fontRegular, 12, 0, today.Summary)
+		sheet.y -= 4
+		sheet.line(fontRegular, 12, t("High:")+" "+number(today.TemperatureHigh)+" "+units.Temperature+"    "+
+			t("Low:")+" "+number(today.TemperatureLow)+" "+units.Temperature+"    "+
+			t("Chance of precipitation:")+" "+number(today.PrecipProbability*100)+" %")
+		sheet.line(fontRegular, 12, t("Humidity:")+" "+number(today.Humidity*100)+" %    "+
+			t("Winds:")+" "+number(today.WindSpeed)+" "+units.Speed+"    "+
+			t("Sunrise:")+" "+printHour(time.Unix(int64(today.SunriseTime), 0).In(location), true)+"    "+
+			t("Sunset:")+" "+printHour(time.Unix(int64(today.SunsetTime), 0).In(location), true))
+
+		highlights := hourlyHighlights(forecast.Hourly.Data, now.In(location))
+		if len(highlights) > 0 {
+			sheet.y -= 8
+			sheet.line(fontBold, 12, t("Hourly"))
+			for _, hour := range highlights {
+				if !sheet.room(12) {
+					break
+				}
+				sheet.y -= 12 * 1.3
+				sheet.page.text(sheet.margin+12, sheet.y, fontRegular, 12, printHour(time.Unix(int64(hour.Time), 0).In(location), false))
+				sheet.page.text(sheet.margin+80, sheet.y, fontRegular, 12, number(hour.Temperature)+" "+units.Temperature)
+				sheet.page.text(sheet.margin+140, sheet.y, fontRegular, 12, number(hour.PrecipProbability*100)+" %")
+				sheet.page.text(sheet.margin+190, sheet.y, fontRegular, 12, hour.Summary)
+			}
+		}
+
+		if len(forecast.Alerts) > 0 {
+			sheet.heading(t("Alerts"))
+			for _, alert := range forecast.Alerts {
+				if !sheet.room(12) {
+					break
+				}
+				top := sheet.y
+				sheet.y -= 4
+				sheet.paragraph(fontBold, 12, 8, alert.Title)
+				if alert.Expires != 0 {
+					expires := time.Unix(int64(alert.Expires), 0).In(location)
+					sheet.paragraph(fontRegular, 10, 8, t("Expires")+" "+activeLocale.weekday(expires)+" "+printHour(expires, true))
+				}
+				sheet.paragraph(fontRegular, 10, 8, alert.Description)
+				sheet.y -= 6
+				page.box(sheet.margin, sheet.y, sheet.width(), top-sheet.y, 1.5, 0)
+				sheet.y -= 6
+			}
+		}
+	}
+
+	wotdInfo, ok := state.Data["wotd"].(wotdType)
+	if ok {
+		sheet.heading(t("Word of the Day"))
+		sheet.line(fontBold, 22, wotdInfo.Word)
+		sheet.y -= 2
+		sheet.line(fontRegular, 12, "\\"+wotdInfo.Pronounce+"\\   "+wotdInfo.POS)
+		sheet.y -= 4
+		for i, def := range wotdInfo.Defs {
+			sheet.paragraph(fontRegular, 12, 12, strconv.Itoa(i+1)+". "+erase(def, ":"))
+		}
+		if wotdInfo.Example != "" {
+			sheet.y -= 4
+			sheet.paragraph(fontRegular, 12, 12, "“"+wotdInfo.Example+"”")
+		}
+	}
+
+	return page.writeTo(w)
+}
+
+// hourlyHighlights picks every third hour left in the day, starting at the
+// coming hour. Late in the evening it runs on into the next morning so the
+// sheet is never empty.
+func hourlyHighlights(hours []hourlyData, now time.Time) []hourlyData {
+	end := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, now.Location())
+	if end.Sub(now) < 6*time.Hour {
+		end = end.Add(12 * time.Hour)
+	}
+
+	var highlights []hourlyData
+	for _, hour := range hours {
+		tm := time.Unix(int64(hour.Time), 0)
+		if tm.Before(now.Truncate(time.Hour)) || !tm.Before(end) {
+			continue
+		}
+		if len(highlights) == 0 || tm.Sub(time.Unix(int64(highlights[len(highlights)-1].Time), 0)) >= 3*time.Hour {
+			highlights = append(highlights, hour)
+		}
+	}
+	return highlights
+}
+
+// printHour formats a time of day the way the locale writes it: with AM/PM
+// for English and on the 24-hour clock otherwise.
+func printHour(tm time.Time, minutes bool) string {
+	switch {
+	case activeLocale.Language != "en":
+		return tm.Format("15:04")
+	case minutes:
+		return tm.Format("3:04 PM")
+	}
+	return tm.Format("3 PM")
+}
+
+// printOnce implements "planner print": fetch the weather and word of the
+// day once and write the daily sheet to a file.
+func printOnce(config configStruct, args []string) {
+	flags := flag.NewFlagSet("print", flag.ExitOnError)
+	out := flags.String("out", "today.pdf", "PDF file to write")
+	paper := flags.String("paper", config.Print.Paper, "paper size, letter or a4")
+	flags.Parse(args)
+
+	widgets := loadWidgets(config, []string{"weather", "wotd"})
+	store := newStateStore()
+	for _, widget := range widgets {
+		fetchWidget(widget, store)
+	}
+
+	file, err := os.Create(*out)
+	if err != nil {
+		log.Fatalln("  FATAL: Error creating PDF:", err)
+	}
+	err = writeTodayPDF(file, *paper, store.snapshot())
+	if err == nil {
+		err = file.Close()
+	}
+	if err != nil {
+		log.Fatalln("  FATAL: Error writing PDF:", err)
+	}
+	log.Printf("  INFO: Wrote %s\n", *out)
+}
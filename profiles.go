@@ -0,0 +1,94 @@
+package main
+
+import (
+	"log"
+	"sort"
+)
+
+// displayProfile is how one screen in the house shows the planner. Every
+// profile is served from the same widget data.
+type displayProfile struct {
+	Layout  layoutConfig
+	Theme   themeConfig
+	Refresh refreshPolicy
+	Render  renderSettings
+}
+
+// refreshPolicy says how a display picks up changes. "push" patches the
+// page over Server-Sent Events; "reload" reloads the whole page every
+// Interval seconds, for browsers such as e-readers that cannot hold a
+// stream open.
+type refreshPolicy struct {
+	Mode     string
+	Interval int
+}
+
+// display is a profile ready to be served.
+type display struct {
+	Name    string
+	Profile displayProfile
+	themes  *themeManager
+}
+
+const defaultDisplay = "default"
+
+// loadDisplays builds every configured profile. The top-level layout, theme
+// and refresh settings form the "default" profile served at "/", unless a
+// profile of that name is configured explicitly.
+func loadDisplays(config configStruct) map[string]*display {
+	profiles := make(map[string]displayProfile)
+	for name, profile := range config.Profiles {
+		profiles[name] = profile
+	}
+	if _, ok := profiles[defaultDisplay]; !ok {
+		profiles[defaultDisplay] = displayProfile{
+			Layout:  config.Layout,
+			Theme:   config.Theme,
+			Refresh: config.Refresh,
+		}
+	}
+
+	displays := make(map[string]*display)
+	for name, profile := range profiles {
+		if len(profile.Layout.Regions) == 0 {
+			profile.Layout = defaultLayout()
+		}
+		switch profile.Refresh.Mode {
+		case "":
+			profile.Refresh.Mode = "push"
+		case "push", "reload":
+		default:
+			log.Fatalf("  FATAL: Unknown refresh mode %q for display %q\n", profile.Refresh.Mode, name)
+		}
+		if profile.Refresh.Mode == "reload" && profile.Refresh.Interval <= 0 {
+			profile.Refresh.Interval = 300
+		}
+		err := profile.Render.applyDefaults()
+		if err != nil {
+			log.Fatalf("  FATAL: Invalid render settings for display %q: %v\n", name, err)
+		}
+
+		displays[name] = &display{
+			Name:    name,
+			Profile: profile,
+			themes:  newThemeManager(config, name, profile.Theme),
+		}
+	}
+	return displays
+}
+
+// displayWidgets lists every widget used by any display, each once.
+func displayWidgets(displays map[string]*display) []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, d := range displays {
+		for _, name := range d.Profile.Layout.widgetNames() {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,336 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+type qotdSettings struct {
+	URL            string // RSS or Atom feed of quotes
+	File           string // local quotes, used when there is no feed or it fails
+	ReloadInterval int    // hours
+	NoRepeatDays   int    // a quote shown is not shown again for this long, 30 by default
+	MinLength      int    // characters
+	MaxLength      int    // characters, 200 by default so the panel does not overflow
+	History        string // where shown quotes are kept, "json/qotd-history.json" by default
+}
+
+// qotdType is one quote. In a JSON quotes file, quotes carry the same
+// fields in lower case; a text file has one quote per line, with the author
+// after a dash.
+type qotdType struct {
+	Text   string `json:"text"`
+	Author string `json:"author"`
+}
+
+// shownQuote is a quote with the day it was shown.
+type shownQuote struct {
+	Date string `json:"date"`
+	qotdType
+}
+
+// qotdWidget shows a quote a day from a feed or a local file, without
+// repeating one within the NoRepeatDays window.
+type qotdWidget struct {
+	settings qotdSettings
+
+	mu      sync.Mutex
+	shown   []shownQuote
+	unsaved bool // shown has quotes the history file lacks
+}
+
+func init() {
+	registerWidget("qotd", func() Widget { return &qotdWidget{} })
+}
+
+func (w *qotdWidget) Name() string { return "qotd" }
+
+func (w *qotdWidget) Settings() interface{} { return &w.settings }
+
+func (w *qotdWidget) Configure(config configStruct) error {
+	if w.settings.URL == "" && w.settings.File == "" {
+		w.settings.URL = config.QotdURL
+	}
+	if w.settings.ReloadInterval == 0 {
+		w.settings.ReloadInterval = config.QotdReloadInterval
+	}
+	if w.settings.ReloadInterval <= 0 {
+		w.settings.ReloadInterval = 12
+	}
+	if w.settings.NoRepeatDays == 0 {
+		w.settings.NoRepeatDays = 30
+	}
+	if w.settings.MaxLength == 0 {
+		w.settings.MaxLength = 200
+	}
+	if w.settings.History == "" {
+		w.settings.History = "json/qotd-history.json"
+	}
+	if w.settings.URL == "" && w.settings.File == "" {
+		return errors.New("no quote feed or file configured")
+	}
+	if w.settings.MaxLength > 0 && w.settings.MinLength > w.settings.MaxLength {
+		return fmt.Errorf("minLength %d is more than maxLength %d", w.settings.MinLength, w.settings.MaxLength)
+	}
+
+	data, err := ioutil.ReadFile(w.settings.History)
+	if err == nil {
+		err = json.Unmarshal(data, &w.shown)
+	}
+	if err != nil && !os.IsNotExist(err) {
+		log.Printf("  INFO: Error reading quote history %s: %v\n", w.settings.History, err)
+	}
+	return nil
+}
+
+func (w *qotdWidget) Interval() time.Duration {
+	return time.Hour * time.Duration(w.settings.ReloadInterval)
+}
+
+// Fetch returns today's quote: the one already shown today if there is
+// one, or else the first quote that fits and has not been shown lately.
+func (w *qotdWidget) Fetch() (interface{}, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	now := time.Now().In(plannerLocation)
+	today := now.Format("2006-01-02")
+	if n := len(w.shown); n > 0 && w.shown[n-1].Date == today {
+		return w.shown[n-1].qotdType, nil
+	}
+
+	quotes, err := w.quotes()
+	if err != nil {
+		return nil, err
+	}
+
+	var fitting []qotdType
+	for _, quote := range quotes {
+		length := len([]rune(quote.Text))
+		if length >= w.settings.MinLength && (w.settings.MaxLength <= 0 || length <= w.settings.MaxLength) {
+			fitting = append(fitting, quote)
+		}
+	}
+	if len(fitting) == 0 {
+		return nil, fmt.Errorf("no quote between %d and %d characters long", w.settings.MinLength, w.settings.MaxLength)
+	}
+
+	// Quotes shown within the window are skipped; if every quote was, the
+	// one shown longest ago comes back first.
+	since := now.AddDate(0, 0, -w.settings.NoRepeatDays).Format("2006-01-02")
+	lastShown := make(map[string]string)
+	for _, shown := range w.shown {
+		lastShown[shown.Text] = shown.Date
+	}
+	start := dayNumber(now) % len(fitting)
+	quote := fitting[start]
+	for i := range fitting {
+		candidate := fitting[(start+i)%len(fitting)]
+		if lastShown[candidate.Text] < since {
+			quote = candidate
+			break
+		}
+		if lastShown[candidate.Text] < lastShown[quote.Text] {
+			quote = candidate
+		}
+	}
+
+	// Only the window is kept; older quotes may be shown again anyway.
+	kept := w.shown[:0]
+	for _, shown := range w.shown {
+		if shown.Date >= since {
+			kept = append(kept, shown)
+		}
+	}
+	w.shown = append(kept, shownQuote{Date: today, qotdType: quote})
+	w.unsaved = true
+	log.Println("  INFO: Finished getQOTD()")
+	return quote, nil
+}
+
+// quotes reads the feed, falling back to the file.
+func (w *qotdWidget) quotes() ([]qotdType, error) {
+	if w.settings.URL != "" {
+		quotes, err := quoteFeed(w.settings.URL)
+		if err == nil && len(quotes) > 0 {
+			return quotes, nil
+		}
+		if err == nil {
+			err = errors.New("feed has no quotes")
+		}
+		if w.settings.File == "" {
+			return nil, err
+		}
+		log.Printf("  INFO: Error reading quote feed, using %s: %v\n", w.settings.File, err)
+	}
+	return loadQuotes(w.settings.File)
+}
+
+// Fetched saves the quotes shown to the history file. The server calls it
+// after every successful fetch, so commands such as "planner fetch qotd" or
+// "planner print" leave the history alone.
+func (w *qotdWidget) Fetched(interface{}) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.unsaved {
+		return
+	}
+
+	data, err := json.MarshalIndent(w.shown, "", "    ")
+	if err == nil {
+		err = ioutil.WriteFile(w.settings.History+".tmp", data, 0644)
+	}
+	if err == nil {
+		err = os.Rename(w.settings.History+".tmp", w.settings.History)
+	}
+	if err != nil {
+		log.Printf("  INFO: Error saving quote history %s: %v\n", w.settings.History, err)
+		return
+	}
+	w.unsaved = false
+}
+
+func (w *qotdWidget) Template() string {
+	return `
+<h2>{{t "Quote of the Day"}}</h2>
+<blockquote id="qotd">
+    <p>&ldquo;{{.Text}}&rdquo;</p>
+    {{- with .Author}}
+    <footer>&mdash; {{.}}</footer>
+    {{- end}}
+</blockquote>`
+}
+
+func (w *qotdWidget) Health() error {
+	if w.settings.File == "" {
+		return nil
+	}
+	_, err := loadQuotes(w.settings.File)
+	return err
+}
+
+// quoteFeedXML covers both RSS items and Atom entries.
+type quoteFeedXML struct {
+	Items []struct {
+		Title       string `xml:"title"`
+		Description string `xml:"description"`
+		Author      string `xml:"author"`
+		Creator     string `xml:"http://purl.org/dc/elements/1.1/ creator"`
+	} `xml:"channel>item"`
+	Entries []struct {
+		Title   string `xml:"title"`
+		Summary string `xml:"summary"`
+		Content string `xml:"content"`
+		Author  struct {
+			Name string `xml:"name"`
+		} `xml:"author"`
+	} `xml:"entry"`
+}
+
+var (
+	// quoteAttribution is the author at the end of a quote, after a dash.
+	quoteAttribution = regexp.MustCompile(`^(.*\S)\s+(?:-{1,2}|–|—|~)\s*([^-–—~"”]+)$`)
+	// quoteTitleAuthor is the author in titles such as "Quote by Mark Twain".
+	quoteTitleAuthor = regexp.MustCompile(`(?i)\bby\s+(.+)$`)
+)
+
+// quoteFeed reads the quotes of an RSS or Atom feed, newest first.
+func quoteFeed(url string) ([]qotdType, error) {
+	data, err := httpGetBytes(url)
+	if err != nil {
+		return nil, err
+	}
+	var feed quoteFeedXML
+	err = xml.Unmarshal(data, &feed)
+	if err != nil {
+		return nil, fmt.Errorf("unmarshaling quote feed: %v", err)
+	}
+
+	var quotes []qotdType
+	add := func(title string, text string, author string) {
+		if text == "" {
+			text, title = title, ""
+		}
+		quote := parseQuote(htmlText(text))
+		if quote.Author == "" {
+			quote.Author = strings.TrimSpace(author)
+		}
+		if match := quoteTitleAuthor.FindStringSubmatch(htmlText(title)); quote.Author == "" && match != nil {
+			quote.Author = match[1]
+		}
+		if quote.Text != "" {
+			quotes = append(quotes, quote)
+		}
+	}
+	for _, item := range feed.Items {
+		author := item.Creator
+		if author == "" {
+			author = item.Author
+		}
+		add(item.Title, item.Description, author)
+	}
+	for _, entry := range feed.Entries {
+		text := entry.Content
+		if text == "" {
+			text = entry.Summary
+		}
+		add(entry.Title, text, entry.Author.Name)
+	}
+	return quotes, nil
+}
+
+// parseQuote splits "Quote text. - Author" and takes the quotation marks
+// off the text.
+func parseQuote(line string) qotdType {
+	var quote qotdType
+	line = strings.TrimSpace(line)
+	// A dash before a short name is an attribution; before anything else
+	// it is part of the quote.
+	if match := quoteAttribution.FindStringSubmatch(line); match != nil && quoteAuthorLike(match[2]) {
+		line, quote.Author = match[1], strings.TrimSpace(match[2])
+	}
+	quote.Text = strings.TrimSpace(strings.Trim(line, `"“”`))
+	return quote
+}
+
+func quoteAuthorLike(author string) bool {
+	words := strings.Fields(author)
+	return len(words) > 0 && len(words) <= 5 && unicode.IsUpper([]rune(words[0])[0])
+}
+
+// loadQuotes reads a JSON quotes file, or a text file with a quote a line.
+func loadQuotes(file string) ([]qotdType, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var quotes []qotdType
+	if strings.EqualFold(filepath.Ext(file), ".json") {
+		err = json.Unmarshal(data, &quotes)
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %v", file, err)
+		}
+	} else {
+		for _, line := range strings.Split(string(data), "\n") {
+			if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+				quotes = append(quotes, parseQuote(line))
+			}
+		}
+	}
+	if len(quotes) == 0 {
+		return nil, fmt.Errorf("%s has no quotes", file)
+	}
+	return quotes, nil
+}
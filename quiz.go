@@ -0,0 +1,464 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"html/template"
+	"io/ioutil"
+	"log"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// familyMember is one person in the household, from the config's
+// "members" list.
+type familyMember struct {
+	Name  string
+	Track string // the word of the day track for them, if there are several
+}
+
+// quizSettings configure the quiz. Its words come from the word of the day
+// archive, and each member is quizzed on the words of their own track.
+type quizSettings struct {
+	Words   int    // how many of the latest words a quiz asks about, 7 by default
+	Choices int    // definitions to choose from per question, 4 by default
+	Day     string // weekday a new quiz starts on, "Sunday" by default
+	File    string // where answers are kept, "json/quiz.json" by default
+}
+
+// quizWidget runs a weekly multiple-choice quiz on the past words of the
+// day at /quiz and shows the family's leaderboard.
+type quizWidget struct {
+	settings quizSettings
+	day      time.Weekday
+	members  []string
+	tracks   map[string]string // member's word of the day track
+	main     string            // the first track, which older archives did not name
+	archive  *wotdArchive
+	results  *quizResults
+}
+
+// quizQuestion asks for the definition of one word. The wrong choices are
+// definitions of other archived words.
+type quizQuestion struct {
+	Word      string
+	Pronounce string
+	Choices   []string
+	Answer    int // index of the right choice
+}
+
+// quizAnswer is one member's answer to one question.
+type quizAnswer struct {
+	Member  string `json:"member"`
+	Quiz    string `json:"quiz"` // YYYY-MM-DD the quiz started
+	Word    string `json:"word"`
+	Correct bool   `json:"correct"`
+	At      string `json:"at"`
+}
+
+// quizStanding is a member's line on the leaderboard.
+type quizStanding struct {
+	Member   string
+	Correct  int
+	Answered int
+	Streak   int // right answers in a row, up to the latest
+	Best     int // longest streak ever
+}
+
+// quizBoard is the widget's data.
+type quizBoard struct {
+	Quiz      string
+	Questions int // the most any member is asked
+	Standings []quizStanding
+}
+
+func init() {
+	registerWidget("quiz", func() Widget { return &quizWidget{} })
+}
+
+func (w *quizWidget) Name() string { return "quiz" }
+
+func (w *quizWidget) Settings() interface{} { return &w.settings }
+
+func (w *quizWidget) Configure(config configStruct) error {
+	if w.settings.Words <= 0 {
+		w.settings.Words = 7
+	}
+	if w.settings.Choices <= 1 {
+		w.settings.Choices = 4
+	}
+	if w.settings.Day == "" {
+		w.settings.Day = "Sunday"
+	}
+	if w.settings.File == "" {
+		w.settings.File = "json/quiz.json"
+	}
+
+	w.day = -1
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		if strings.EqualFold(day.String(), w.settings.Day) {
+			w.day = day
+		}
+	}
+	if w.day < 0 {
+		return fmt.Errorf("unknown quiz day %q", w.settings.Day)
+	}
+
+	w.members = nil
+	w.tracks = make(map[string]string)
+	w.main = wotdMainTrack(config)
+	for _, member := range config.Members {
+		if member.Name == "" {
+			return errors.New("members need a name")
+		}
+		w.members = append(w.members, member.Name)
+		w.tracks[member.Name] = member.Track
+		if member.Track == "" {
+			w.tracks[member.Name] = w.main
+		}
+	}
+	if len(w.members) == 0 {
+		log.Println("  INFO: No members configured, the quiz is off")
+	}
+
+	w.archive = openWotdArchive(config.WotdArchive)
+	w.results = openQuizResults(w.settings.File)
+	return nil
+}
+
+func (w *quizWidget) Interval() time.Duration { return time.Hour }
+
+func (w *quizWidget) Fetch() (interface{}, error) {
+	if len(w.members) == 0 {
+		return quizBoard{}, nil
+	}
+	now := time.Now()
+	board := quizBoard{Quiz: w.quizDate(now), Standings: w.results.standings(w.members)}
+	for _, member := range w.members {
+		_, questions := w.questions(now, w.tracks[member])
+		if len(questions) > board.Questions {
+			board.Questions = len(questions)
+		}
+	}
+	return board, nil
+}
+
+func (w *quizWidget) Template() string {
+	return `{{if .Quiz}}
+<h2>{{t "Vocabulary quiz"}}</h2>
+{{- if .Standings}}
+<table id="quizBoard">
+    {{- range .Standings}}
+    <tr><td class="quizMember">{{.Member}}</td><td>{{.Correct}}/{{.Answered}}</td><td>{{t "Streak"}} {{.Streak}}</td><td>{{t "Best"}} {{.Best}}</td></tr>
+    {{- end}}
+</table>
+{{- end}}
+{{- if .Questions}}
+<a class="quizLink" href="/quiz">{{t "Take the quiz"}}</a>
+{{- end}}
+{{- end}}`
+}
+
+func (w *quizWidget) Health() error {
+	if len(w.members) == 0 {
+		return errors.New("no members configured to take the quiz")
+	}
+	return nil
+}
+
+// quizDate is the day the quiz running at now started.
+func (w *quizWidget) quizDate(now time.Time) string {
+	now = now.In(plannerLocation)
+	back := (int(now.Weekday()) - int(w.day) + 7) % 7
+	return now.AddDate(0, 0, -back).Format("2006-01-02")
+}
+
+// questions returns the quiz running at now for a track: one question for
+// each of the track's latest words shown up to the day it started, oldest
+// first. A word shown more than once is asked once. The choices are other
+// words of the track, shuffled the same way for everyone.
+func (w *quizWidget) questions(now time.Time, track string) (string, []quizQuestion) {
+	quiz := w.quizDate(now)
+	var words []archivedWord
+	for _, word := range w.archive.search("") {
+		if word.Track == track || word.Track == "" && track == w.main {
+			words = append(words, word)
+		}
+	}
+
+	var pool []string
+	seen := make(map[string]bool)
+	for _, word := range words {
+		if def := quizDefinition(word.Defs); def != "" && !seen[def] {
+			seen[def] = true
+			pool = append(pool, def)
+		}
+	}
+	if len(pool) < 2 {
+		return quiz, nil
+	}
+
+	var questions []quizQuestion
+	asked := make(map[string]bool)
+	for _, word := range words {
+		if len(questions) == w.settings.Words {
+			break
+		}
+		answer := quizDefinition(word.Defs)
+		if word.Date > quiz || answer == "" || asked[word.Word] {
+			continue
+		}
+		asked[word.Word] = true
+
+		hash := fnv.New64a()
+		hash.Write([]byte(quiz + "\n" + word.Word))
+		random := rand.New(rand.NewSource(int64(hash.Sum64())))
+
+		choices := []string{answer}
+		for _, i := range random.Perm(len(pool)) {
+			if len(choices) == w.settings.Choices {
+				break
+			}
+			if pool[i] != answer {
+				choices = append(choices, pool[i])
+			}
+		}
+		random.Shuffle(len(choices), func(i, j int) { choices[i], choices[j] = choices[j], choices[i] })
+
+		question := quizQuestion{Word: word.Word, Pronounce: word.Pronounce, Choices: choices}
+		for i, choice := range choices {
+			if choice == answer {
+				question.Answer = i
+			}
+		}
+		questions = append([]quizQuestion{question}, questions...)
+	}
+	return quiz, questions
+}
+
+// quizDefinition is the definition a word is asked by: its first.
+func quizDefinition(defs []string) string {
+	if len(defs) == 0 {
+		return ""
+	}
+	return strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(defs[0]), ":"))
+}
+
+// quizResults keeps every answer in a JSON file, in the order given.
+type quizResults struct {
+	path string
+
+	mu      sync.RWMutex
+	answers []quizAnswer
+}
+
+func openQuizResults(path string) *quizResults {
+	results := &quizResults{path: path}
+	data, err := ioutil.ReadFile(path)
+	if err == nil {
+		err = json.Unmarshal(data, &results.answers)
+	}
+	if err != nil && !os.IsNotExist(err) {
+		log.Printf("  INFO: Error reading quiz results %s: %v\n", path, err)
+	}
+	return results
+}
+
+// answered reports whether member has answered the question on word of quiz.
+func (r *quizResults) answered(member string, quiz string, word string) (quizAnswer, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.find(member, quiz, word)
+}
+
+// find is answered for callers holding the lock.
+func (r *quizResults) find(member string, quiz string, word string) (quizAnswer, bool) {
+	for _, answer := range r.answers {
+		if answer.Member == member && answer.Quiz == quiz && answer.Word == word {
+			return answer, true
+		}
+	}
+	return quizAnswer{}, false
+}
+
+// record stores an answer unless the question was answered already, so a
+// second tap cannot change the score.
+func (r *quizResults) record(answer quizAnswer) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.find(answer.Member, answer.Quiz, answer.Word); ok {
+		return
+	}
+	r.answers = append(r.answers, answer)
+	data, err := json.MarshalIndent(r.answers, "", "    ")
+	if err == nil {
+		err = ioutil.WriteFile(r.path+".tmp", data, 0644)
+	}
+	if err == nil {
+		err = os.Rename(r.path+".tmp", r.path)
+	}
+	if err != nil {
+		log.Printf("  INFO: Error saving quiz results %s: %v\n", r.path, err)
+	}
+}
+
+// standings ranks members by right answers, then by their best streak.
+func (r *quizResults) standings(members []string) []quizStanding {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	byMember := make(map[string]*quizStanding)
+	var standings []*quizStanding
+	for _, member := range members {
+		standing := &quizStanding{Member: member}
+		byMember[member] = standing
+		standings = append(standings, standing)
+	}
+	for _, answer := range r.answers {
+		standing, ok := byMember[answer.Member]
+		if !ok {
+			continue
+		}
+		standing.Answered++
+		if !answer.Correct {
+			standing.Streak = 0
+			continue
+		}
+		standing.Correct++
+		standing.Streak++
+		if standing.Streak > standing.Best {
+			standing.Best = standing.Streak
+		}
+	}
+
+	sort.SliceStable(standings, func(i, j int) bool {
+		if standings[i].Correct != standings[j].Correct {
+			return standings[i].Correct > standings[j].Correct
+		}
+		return standings[i].Best > standings[j].Best
+	})
+	var list []quizStanding
+	for _, standing := range standings {
+		list = append(list, *standing)
+	}
+	return list
+}
+
+// quizPage is what templates/quiz.html is executed with.
+type quizPage struct {
+	Locale    *localeCatalog
+	ThemeHref string
+	Members   []string
+	Member    string
+	Quiz      string
+	Question  *quizQuestion
+	Number    int // of Question, from 1
+	Total     int
+	Last      *quizFeedback
+	Score     int
+	Standings []quizStanding
+}
+
+// quizFeedback tells how the previous question went.
+type quizFeedback struct {
+	Word    string
+	Correct bool
+	Answer  string
+}
+
+// quizHandler serves the quiz: /quiz asks who is playing, /quiz?member=Ann
+// asks Ann's next question, and answers are POSTed back to /quiz.
+func quizHandler(config configStruct, w *quizWidget, d *display) http.HandlerFunc {
+	file := filepath.Join(filepath.Dir(config.HTMLFile), "quiz.html")
+	tmpl, err := template.New(filepath.Base(file)).Funcs(templateFuncs).ParseFiles(file)
+	if err != nil {
+		log.Fatalf("  FATAL: Error parsing %s: %v\n", file, err)
+	}
+
+	known := func(member string) bool {
+		for _, name := range w.members {
+			if name == member {
+				return true
+			}
+		}
+		return false
+	}
+
+	return func(rw http.ResponseWriter, req *http.Request) {
+		if len(w.members) == 0 {
+			http.NotFound(rw, req)
+			return
+		}
+		now := time.Now()
+
+		if req.Method == http.MethodPost {
+			member := req.FormValue("member")
+			word := req.FormValue("word")
+			choice, err := strconv.Atoi(req.FormValue("choice"))
+			if !known(member) || err != nil {
+				http.Error(rw, "unknown member or choice", http.StatusBadRequest)
+				return
+			}
+			quiz, questions := w.questions(now, w.tracks[member])
+			for _, question := range questions {
+				if question.Word == word {
+					w.results.record(quizAnswer{
+						Member:  member,
+						Quiz:    quiz,
+						Word:    word,
+						Correct: choice == question.Answer,
+						At:      now.In(plannerLocation).Format(time.RFC3339),
+					})
+					requestRefresh(w.Name())
+				}
+			}
+			http.Redirect(rw, req, "/quiz?member="+url.QueryEscape(member)+"&last="+url.QueryEscape(word), http.StatusSeeOther)
+			return
+		}
+
+		page := quizPage{
+			Locale:    activeLocale,
+			ThemeHref: d.themes.Href(),
+			Members:   w.members,
+			Quiz:      w.quizDate(now),
+		}
+		if member := req.URL.Query().Get("member"); known(member) {
+			quiz, questions := w.questions(now, w.tracks[member])
+			page.Member = member
+			page.Total = len(questions)
+			for i, question := range questions {
+				answer, ok := w.results.answered(member, quiz, question.Word)
+				if ok && answer.Correct {
+					page.Score++
+				}
+				if ok && question.Word == req.URL.Query().Get("last") {
+					page.Last = &quizFeedback{Word: question.Word, Correct: answer.Correct, Answer: question.Choices[question.Answer]}
+				}
+				if !ok && page.Question == nil {
+					question := question
+					page.Question = &question
+					page.Number = i + 1
+				}
+			}
+			if page.Question == nil {
+				page.Standings = w.results.standings(w.members)
+			}
+		}
+
+		err := tmpl.Execute(rw, page)
+		if err != nil {
+			log.Println("  INFO: Error rendering quiz:", err)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bytes"
+	"html/template"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Functions available to the page and every widget template.
+var templateFuncs = template.FuncMap{
+	"t":       func(text string) string { return activeLocale.translate(text) },
+	"number":  func(x float64, places int) string { return activeLocale.formatNumber(x, places) },
+	"percent": func(x float64) string { return activeLocale.formatNumber(x*100, 0) },
+	"weekday": getWeekday,
+	"erase":   erase,
+	"inc":     func(i int) int { return i + 1 },
+	"join":    strings.Join,
+	"dayName": func(date string) string { return activeLocale.weekday(parseDate(date)) },
+	"date":    func(date string) string { return activeLocale.formatDate(parseDate(date)) },
+}
+
+// parseDate reads a YYYY-MM-DD date as stored in the word archive.
+func parseDate(date string) time.Time {
+	tm, _ := time.Parse("2006-01-02", date)
+	return tm
+}
+
+// renderer is the single consumer of the state store. It renders each
+// widget's fragment when its data changes, pushes the fragment to connected
+// browsers and composes the full page on request.
+type renderer struct {
+	page      *template.Template
+	templates map[string]*template.Template
+
+	mu        sync.RWMutex
+	fragments map[string]template.HTML
+}
+
+// pageData is what the page template is executed with.
+type pageData struct {
+	Locale    *localeCatalog
+	Display   string
+	Layout    layoutConfig
+	Refresh   refreshPolicy
+	ThemeHref string
+}
+
+func newRenderer(config configStruct, widgets []Widget) *renderer {
+	r := &renderer{
+		templates: make(map[string]*template.Template),
+		fragments: make(map[string]template.HTML),
+	}
+
+	for _, widget := range widgets {
+		tmpl, err := template.New(widget.Name()).Funcs(templateFuncs).Parse(widget.Template())
+		if err != nil {
+			log.Fatalf("  FATAL: Error parsing template of widget %q: %v\n", widget.Name(), err)
+		}
+		r.templates[widget.Name()] = tmpl
+	}
+
+	pageFuncs := template.FuncMap{"widget": r.fragment}
+	page, err := template.New(filepath.Base(config.HTMLFile)).Funcs(templateFuncs).Funcs(pageFuncs).ParseFiles(config.HTMLFile)
+	if err != nil {
+		log.Fatalf("  FATAL: Error parsing %s: %v\n", config.HTMLFile, err)
+	}
+	r.page = page
+
+	return r
+}
+
+// run re-renders whatever changed in the store until the program exits.
+func (r *renderer) run(store *stateStore) {
+	rendered := make(map[string]uint64)
+	changes := store.watch()
+
+	for {
+		state := store.snapshot()
+
+		for name, version := range state.Versions {
+			if rendered[name] == version {
+				continue
+			}
+			rendered[name] = version
+
+			tmpl, ok := r.templates[name]
+			if !ok {
+				continue
+			}
+			var buf bytes.Buffer
+			err := tmpl.Execute(&buf, state.Data[name])
+			if err != nil {
+				log.Printf("  INFO: Error rendering %s: %v\n", name, err)
+				continue
+			}
+			fragment := template.HTML(buf.String())
+
+			r.mu.Lock()
+			r.fragments[name] = fragment
+			r.mu.Unlock()
+
+			events.publish("widget", name, map[string]string{"name": name, "html": buf.String()})
+		}
+
+		<-changes
+	}
+}
+
+// fragment returns the latest rendered HTML of a widget.
+func (r *renderer) fragment(name string) template.HTML {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.fragments[name]
+}
+
+func (r *renderer) servePage(w http.ResponseWriter, req *http.Request, d *display) {
+	var buf bytes.Buffer
+	data := pageData{
+		Locale:    activeLocale,
+		Display:   d.Name,
+		Layout:    d.Profile.Layout,
+		Refresh:   d.Profile.Refresh,
+		ThemeHref: d.themes.Href(),
+	}
+	err := r.page.Execute(&buf, data)
+	if err != nil {
+		log.Println("  INFO: Error rendering page:", err)
+		http.Error(w, "error rendering page", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Write(buf.Bytes())
+}
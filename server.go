@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// widgetHealth is one entry of the /health report.
+type widgetHealth struct {
+	Name    string `json:"name"`
+	Healthy bool   `json:"healthy"`
+	Problem string `json:"problem,omitempty"`
+	widgetStatus
+}
+
+func startServer(config configStruct, r *renderer, store *stateStore, widgets []Widget, displays map[string]*display) {
+	mux := http.NewServeMux()
+
+	mux.Handle("/events", events)
+	mux.Handle("/css/", http.FileServer(http.Dir(".")))
+	mux.Handle("/js/", http.FileServer(http.Dir(".")))
+	mux.Handle("/photos/", http.StripPrefix("/photos/", http.FileServer(http.Dir(photoDir(config, widgets)))))
+	mux.Handle("/audio/", http.StripPrefix("/audio/", http.FileServer(http.Dir(audioDir(config)))))
+	mux.HandleFunc("/themes/", func(w http.ResponseWriter, req *http.Request) {
+		d, ok := displays[req.URL.Query().Get("display")]
+		if !ok {
+			d = displays[defaultDisplay]
+		}
+		name := strings.TrimSuffix(strings.TrimPrefix(req.URL.Path, "/themes/"), ".css")
+		css, err := d.themes.css(name)
+		if err != nil {
+			http.NotFound(w, req)
+			return
+		}
+		w.Header().Set("Content-Type", "text/css; charset=utf-8")
+		w.Write(css)
+	})
+	mux.HandleFunc("/health", func(w http.ResponseWriter, req *http.Request) {
+		serveHealth(w, store, widgets)
+	})
+	mux.HandleFunc("/api/state", func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(store.snapshot())
+	})
+	mux.HandleFunc("/api/refresh/", func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost {
+			http.Error(w, "use POST", http.StatusMethodNotAllowed)
+			return
+		}
+		if !requestRefresh(strings.TrimPrefix(req.URL.Path, "/api/refresh/")) {
+			http.NotFound(w, req)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	})
+	mux.HandleFunc("/render.png", func(w http.ResponseWriter, req *http.Request) {
+		name := req.URL.Query().Get("profile")
+		if name == "" {
+			name = defaultDisplay
+		}
+		d, ok := displays[name]
+		if !ok {
+			http.NotFound(w, req)
+			return
+		}
+		w.Header().Set("Content-Type", "image/png")
+		err := writePNG(w, d, store.snapshot(), widgetsByName(widgets))
+		if err != nil {
+			log.Println("  INFO: Error rendering PNG:", err)
+		}
+	})
+	mux.HandleFunc("/print/today.pdf", func(w http.ResponseWriter, req *http.Request) {
+		paper := req.URL.Query().Get("paper")
+		if paper == "" {
+			paper = config.Print.Paper
+		}
+		var pdf bytes.Buffer
+		err := writeTodayPDF(&pdf, paper, store.snapshot())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Write(pdf.Bytes())
+	})
+	mux.HandleFunc("/wotd/history", historyHandler(config, openWotdArchive(config.WotdArchive), displays[defaultDisplay]))
+	if quiz, ok := widgetsByName(widgets)["quiz"].(*quizWidget); ok {
+		mux.HandleFunc("/quiz", quizHandler(config, quiz, displays[defaultDisplay]))
+	}
+	mux.HandleFunc("/display/", func(w http.ResponseWriter, req *http.Request) {
+		d, ok := displays[strings.TrimPrefix(req.URL.Path, "/display/")]
+		if !ok {
+			http.NotFound(w, req)
+			return
+		}
+		r.servePage(w, req, d)
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/" {
+			http.NotFound(w, req)
+			return
+		}
+		r.servePage(w, req, displays[defaultDisplay])
+	})
+
+	log.Printf("  INFO: Serving planner on %s\n", config.ListenAddr)
+	err := http.ListenAndServe(config.ListenAddr, mux)
+	log.Fatalln("  FATAL: HTTP server stopped:", err)
+}
+
+// serveHealth reports every widget's own health check along with the
+// outcome of its recent fetches.
+func serveHealth(w http.ResponseWriter, store *stateStore, widgets []Widget) {
+	state := store.snapshot()
+
+	report := make([]widgetHealth, 0, len(widgets))
+	for _, widget := range widgets {
+		health := widgetHealth{
+			Name:         widget.Name(),
+			Healthy:      true,
+			widgetStatus: state.Status[widget.Name()],
+		}
+		if err := widget.Health(); err != nil {
+			health.Healthy = false
+			health.Problem = err.Error()
+		} else if health.Failures > 0 {
+			health.Healthy = false
+		}
+		report = append(report, health)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"reflect"
+	"sync"
+	"time"
+)
+
+// widgetStatus is what the scheduler knows about a widget's recent fetches.
+type widgetStatus struct {
+	LastSuccess time.Time `json:"lastSuccess"`
+	LastError   string    `json:"lastError,omitempty"`
+	LastErrorAt time.Time `json:"lastErrorAt"`
+	Failures    int       `json:"failures"`
+}
+
+// plannerState is the single source of truth for everything the planner
+// shows. Widgets publish into it through stateStore and never touch the page.
+type plannerState struct {
+	// Data holds the latest successful Fetch result of each widget.
+	Data map[string]interface{} `json:"data"`
+
+	// Versions counts the results published by each widget.
+	Versions map[string]uint64 `json:"versions"`
+
+	Status map[string]widgetStatus `json:"status"`
+}
+
+// stateStore guards plannerState and signals watchers after every change.
+// Published values are replaced wholesale and never modified in place, so a
+// snapshot can safely share them.
+type stateStore struct {
+	mu       sync.RWMutex
+	state    plannerState
+	watchers []chan struct{}
+}
+
+func newStateStore() *stateStore {
+	return &stateStore{
+		state: plannerState{
+			Data:     make(map[string]interface{}),
+			Versions: make(map[string]uint64),
+			Status:   make(map[string]widgetStatus),
+		},
+	}
+}
+
+// set publishes a widget's latest data. Data equal to what the store
+// already holds only refreshes the widget's status.
+func (s *stateStore) set(widget string, data interface{}) {
+	s.mu.Lock()
+	status := s.state.Status[widget]
+	status.LastSuccess = time.Now()
+	status.Failures = 0
+	s.state.Status[widget] = status
+
+	_, exists := s.state.Data[widget]
+	if exists && reflect.DeepEqual(s.state.Data[widget], data) {
+		s.mu.Unlock()
+		return
+	}
+	s.state.Data[widget] = data
+	s.state.Versions[widget]++
+	watchers := s.watchers
+	s.mu.Unlock()
+
+	for _, watcher := range watchers {
+		// Signals coalesce: a watcher that is behind will pick up every
+		// change from its next snapshot.
+		select {
+		case watcher <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// setError records a failed fetch. The widget keeps showing its last data.
+func (s *stateStore) setError(widget string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	status := s.state.Status[widget]
+	status.LastError = err.Error()
+	status.LastErrorAt = time.Now()
+	status.Failures++
+	s.state.Status[widget] = status
+}
+
+// snapshot returns a copy of the current state.
+func (s *stateStore) snapshot() plannerState {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	state := plannerState{
+		Data:     make(map[string]interface{}, len(s.state.Data)),
+		Versions: make(map[string]uint64, len(s.state.Versions)),
+		Status:   make(map[string]widgetStatus, len(s.state.Status)),
+	}
+	for widget, data := range s.state.Data {
+		state.Data[widget] = data
+	}
+	for widget, version := range s.state.Versions {
+		state.Versions[widget] = version
+	}
+	for widget, status := range s.state.Status {
+		state.Status[widget] = status
+	}
+	return state
+}
+
+// watch returns a channel that is signalled whenever the state changes.
+func (s *stateStore) watch() <-chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	watcher := make(chan struct{}, 1)
+	s.watchers = append(s.watchers, watcher)
+	return watcher
+}
+
+// forecast returns the weather widget's latest forecast.
+func (s plannerState) forecast() (darkskyForecast, bool) {
+	forecast, ok := s.Data["weather"].(darkskyForecast)
+	return forecast, ok
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"embed"
+	"errors"
+	"io/ioutil"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+//go:embed themes/*.css
+var bundledThemes embed.FS
+
+// themeConfig picks the stylesheet shown during the day and at night. Dir
+// holds custom themes, which take precedence over the bundled ones.
+type themeConfig struct {
+	Day   string
+	Night string
+	Dir   string
+}
+
+// themeManager switches between the day and night themes at sunrise and
+// sunset and tells connected pages to swap their stylesheet.
+type themeManager struct {
+	display  string
+	config   themeConfig
+	southern bool
+
+	mu      sync.RWMutex
+	current string
+}
+
+func newThemeManager(config configStruct, display string, theme themeConfig) *themeManager {
+	t := &themeManager{display: display, config: theme}
+	if t.config.Day == "" {
+		t.config.Day = "light"
+	}
+	if t.config.Night == "" {
+		t.config.Night = "dark"
+	}
+	latitude, err := strconv.ParseFloat(config.Latitude, 64)
+	t.southern = err == nil && latitude < 0
+
+	t.current = t.resolve(t.config.Day, time.Now().In(plannerLocation))
+	return t
+}
+
+// run re-evaluates the theme whenever the forecast changes and at every
+// sunrise and sunset.
+func (t *themeManager) run(store *stateStore) {
+	changes := store.watch()
+
+	for {
+		now := time.Now().In(plannerLocation)
+		name, next := t.pick(store.snapshot(), now)
+
+		t.mu.Lock()
+		changed := name != t.current
+		t.current = name
+		t.mu.Unlock()
+
+		if changed {
+			log.Printf("  INFO: Switching display %s to %s theme\n", t.display, name)
+			events.publish("theme", "theme:"+t.display, map[string]string{
+				"display": t.display,
+				"name":    name,
+				"href":    t.href(name),
+			})
+		}
+
+		timer := time.NewTimer(next.Sub(now))
+		select {
+		case <-changes:
+		case <-timer.C:
+		}
+		timer.Stop()
+	}
+}
+
+// Current is the name of the theme in effect.
+func (t *themeManager) Current() string {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.current
+}
+
+// Href is the stylesheet URL of the theme in effect.
+func (t *themeManager) Href() string {
+	return t.href(t.Current())
+}
+
+func (t *themeManager) href(name string) string {
+	return "/themes/" + name + ".css?display=" + url.QueryEscape(t.display)
+}
+
+// pick returns the theme for now and when it should next be re-evaluated.
+// Sunrise and sunset come from today's forecast; until one arrives, day runs
+// from 7am to 7pm.
+func (t *themeManager) pick(state plannerState, now time.Time) (string, time.Time) {
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	sunrise := midnight.Add(7 * time.Hour)
+	sunset := midnight.Add(19 * time.Hour)
+	tomorrow := sunrise.AddDate(0, 0, 1)
+
+	if forecast, ok := state.forecast(); ok && len(forecast.Daily.Data) > 0 {
+		// Use the first day whose sunset is still ahead.
+		days := forecast.Daily.Data
+		i := 0
+		for i < len(days)-1 && time.Unix(int64(days[i].SunsetTime), 0).Before(now) {
+			i++
+		}
+		sunrise = time.Unix(int64(days[i].SunriseTime), 0)
+		sunset = time.Unix(int64(days[i].SunsetTime), 0)
+		tomorrow = sunrise.AddDate(0, 0, 1)
+		if i+1 < len(days) {
+			tomorrow = time.Unix(int64(days[i+1].SunriseTime), 0)
+		}
+	}
+
+	switch {
+	case now.Before(sunrise):
+		return t.resolve(t.config.Night, now), sunrise
+	case now.Before(sunset):
+		return t.resolve(t.config.Day, now), sunset
+	case now.Before(tomorrow):
+		return t.resolve(t.config.Night, now), tomorrow
+	default:
+		// The forecast is stale; check again in an hour.
+		return t.resolve(t.config.Night, now), now.Add(time.Hour)
+	}
+}
+
+// resolve maps "seasonal" onto the theme for the current season.
+func (t *themeManager) resolve(name string, now time.Time) string {
+	if name != "seasonal" {
+		return name
+	}
+	seasons := []string{"winter", "spring", "summer", "autumn"}
+	season := int(now.Month()) % 12 / 3
+	if t.southern {
+		season = (season + 2) % 4
+	}
+	return "seasonal-" + seasons[season]
+}
+
+// css returns a theme's stylesheet, preferring the custom theme directory.
+func (t *themeManager) css(name string) ([]byte, error) {
+	if name == "" || strings.ContainsAny(name, `/\.`) {
+		return nil, errors.New("invalid theme name")
+	}
+	if t.config.Dir != "" {
+		css, err := ioutil.ReadFile(filepath.Join(t.config.Dir, name+".css"))
+		if err == nil || !os.IsNotExist(err) {
+			return css, err
+		}
+	}
+	return bundledThemes.ReadFile("themes/" + name + ".css")
+}
@@ -0,0 +1,415 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// The terminal dashboard is a client of a running planner: it reads the
+// same state the page is rendered from through /api/state, redraws on every
+// Server-Sent Event, and asks the server to refetch widgets on request.
+
+const (
+	ansiReset  = "\x1b[0m"
+	ansiBold   = "\x1b[1m"
+	ansiDim    = "\x1b[2m"
+	ansiRed    = "\x1b[31m"
+	ansiGreen  = "\x1b[32m"
+	ansiYellow = "\x1b[33m"
+	ansiCyan   = "\x1b[36m"
+)
+
+// tuiState is the part of /api/state the dashboard shows.
+type tuiState struct {
+	Data struct {
+		Weather *darkskyForecast `json:"weather"`
+		Wotd    *wotdType        `json:"wotd"`
+	} `json:"data"`
+	Status map[string]widgetStatus `json:"status"`
+}
+
+type tui struct {
+	server  string
+	state   tuiState
+	modules []string
+	problem string
+	notice  string
+	rows    int
+	cols    int
+}
+
+// runTUI implements "planner tui".
+func runTUI(config configStruct, args []string) {
+	flags := flag.NewFlagSet("tui", flag.ExitOnError)
+	server := flags.String("server", serverURL(config.ListenAddr), "URL of the running planner")
+	flags.Parse(args)
+
+	t := &tui{server: strings.TrimSuffix(*server, "/")}
+	restore, err := rawTerminal()
+	if err != nil {
+		log.Fatalln("  FATAL: Error setting up the terminal:", err)
+	}
+	// Use the alternate screen and hide the cursor while running.
+	fmt.Print("\x1b[?1049h\x1b[?25l")
+	defer func() {
+		fmt.Print("\x1b[?25h\x1b[?1049l")
+		restore()
+	}()
+
+	keys := make(chan byte)
+	go readKeys(keys)
+	changes := make(chan struct{}, 1)
+	go t.followEvents(changes)
+	ticker := time.NewTicker(500 * time.Millisecond)
+	defer ticker.Stop()
+
+	t.load()
+	t.resize()
+	t.draw()
+	polls := 0
+	for {
+		select {
+		case key, ok := <-keys:
+			if !ok || !t.handleKey(key) {
+				return
+			}
+			t.draw()
+		case <-changes:
+			t.load()
+			t.draw()
+		case <-ticker.C:
+			// The size is polled as there is no portable resize signal;
+			// the state is reloaded every few seconds so fetch times and
+			// failures that do not change any widget still show up.
+			polls++
+			resized := t.resize()
+			if polls%10 == 0 {
+				t.load()
+			}
+			if resized || polls%10 == 0 {
+				t.draw()
+			}
+		}
+	}
+}
+
+// serverURL turns a listen address such as ":8080" into a URL to dial.
+func serverURL(listenAddr string) string {
+	if strings.HasPrefix(listenAddr, ":") {
+		listenAddr = "localhost" + listenAddr
+	}
+	return "http://" + listenAddr
+}
+
+// rawTerminal switches the controlling terminal to raw mode with stty and
+// returns a function that puts it back.
+func rawTerminal() (func(), error) {
+	saved, err := stty("-g")
+	if err != nil {
+		return nil, err
+	}
+	_, err = stty("raw", "-echo")
+	if err != nil {
+		return nil, err
+	}
+	return func() { stty(strings.TrimSpace(saved)) }, nil
+}
+
+func stty(args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = os.Stdin
+	out, err := cmd.Output()
+	return string(out), err
+}
+
+func readKeys(keys chan<- byte) {
+	reader := bufio.NewReader(os.Stdin)
+	for {
+		key, err := reader.ReadByte()
+		if err != nil {
+			close(keys)
+			return
+		}
+		keys <- key
+	}
+}
+
+// followEvents signals changes whenever the server pushes an event,
+// reconnecting after the server goes away.
+func (t *tui) followEvents(changes chan<- struct{}) {
+	for {
+		resp, err := http.Get(t.server + "/events")
+		if err == nil {
+			scanner := bufio.NewScanner(resp.Body)
+			for scanner.Scan() {
+				if strings.HasPrefix(scanner.Text(), "event:") {
+					select {
+					case changes <- struct{}{}:
+					default:
+					}
+				}
+			}
+			resp.Body.Close()
+		}
+		time.Sleep(5 * time.Second)
+	}
+}
+
+func (t *tui) load() {
+	resp, err := http.Get(t.server + "/api/state")
+	if err != nil {
+		t.problem = "Cannot reach " + t.server
+		return
+	}
+	defer resp.Body.Close()
+
+	var state tuiState
+	err = json.NewDecoder(resp.Body).Decode(&state)
+	if err != nil {
+		t.problem = "Bad state from server: " + err.Error()
+		return
+	}
+	t.state = state
+	t.problem = ""
+	t.modules = t.modules[:0]
+	for name := range state.Status {
+		t.modules = append(t.modules, name)
+	}
+	sort.Strings(t.modules)
+}
+
+// resize reads the terminal size and reports whether it changed.
+func (t *tui) resize() bool {
+	out, err := stty("size")
+	if err != nil {
+		return false
+	}
+	var rows, cols int
+	fmt.Sscan(out, &rows, &cols)
+	if rows <= 0 || cols <= 0 || (rows == t.rows && cols == t.cols) {
+		return false
+	}
+	t.rows, t.cols = rows, cols
+	return true
+}
+
+// handleKey acts on a key press and reports whether to keep running.
+func (t *tui) handleKey(key byte) bool {
+	switch {
+	case key == 'q' || key == 3: // q or Ctrl-C
+		return false
+	case key == 'r':
+		for _, name := range t.modules {
+			t.refresh(name)
+		}
+		t.notice = "Refreshing all modules"
+	case key >= '1' && key <= '9' && int(key-'1') < len(t.modules):
+		name := t.modules[key-'1']
+		t.refresh(name)
+		t.notice = "Refreshing " + name
+	}
+	return true
+}
+
+func (t *tui) refresh(name string) {
+	resp, err := http.Post(t.server+"/api/refresh/"+name, "text/plain", nil)
+	if err != nil {
+		t.problem = "Cannot reach " + t.server
+		return
+	}
+	resp.Body.Close()
+}
+
+func (t *tui) draw() {
+	width := t.cols
+	if width < 20 {
+		width = 20
+	}
+	tr := activeLocale.translate
+
+	now := time.Now().In(plannerLocation)
+	lines := []string{
+		ansiBold + tr("Family Planner") + ansiReset + "  " + activeLocale.formatDate(now) + " " + now.Format("15:04"),
+	}
+	lines = append(lines, box(tr("Weather"), width, t.weatherLines(width-4))...)
+	lines = append(lines, box(tr("Word of the Day"), width, t.wotdLines(width-4))...)
+	lines = append(lines, box(tr("Modules"), width, t.statusLines())...)
+
+	footer := ansiDim + "r refresh all   1-9 refresh module   q quit" + ansiReset
+	if t.notice != "" {
+		footer += "   " + t.notice
+	}
+	if t.problem != "" {
+		footer = ansiRed + t.problem + ansiReset
+	}
+
+	height := t.rows
+	if height < 2 {
+		height = 24
+	}
+	if len(lines) > height-1 {
+		lines = lines[:height-1]
+	}
+	lines = append(lines, footer)
+
+	var screen strings.Builder
+	screen.WriteString("\x1b[H")
+	for i, line := range lines {
+		if i > 0 {
+			screen.WriteString("\r\n")
+		}
+		screen.WriteString(fitLine(line, width))
+	}
+	screen.WriteString("\x1b[J")
+	os.Stdout.WriteString(screen.String())
+}
+
+func (t *tui) weatherLines(width int) []string {
+	forecast := t.state.Data.Weather
+	if forecast == nil {
+		return []string{ansiDim + "No forecast yet" + ansiReset}
+	}
+	tr := activeLocale.translate
+	number := func(x float64) string { return activeLocale.formatNumber(x, 0) }
+
+	current := forecast.Current
+	lines := []string{
+		ansiBold + tr("Current") + ansiReset + "  " + number(current.Temperature) + forecast.Units.Temperature + "  " + current.Summary + "  " +
+			tr("Humidity:") + " " + number(current.Humidity*100) + "%  " + tr("Winds:") + " " + number(current.WindSpeed) + " " + forecast.Units.Speed,
+	}
+	for _, day := range forecast.Daily.Data {
+		lines = append(lines, fmt.Sprintf("%s%-10s%s %s%3s%s%s %s%3s%s%s  %s",
+			ansiCyan, getWeekday(day.Time, forecast.Timezone), ansiReset,
+			ansiCyan, number(day.TemperatureLow), forecast.Units.Temperature, ansiReset,
+			ansiYellow, number(day.TemperatureHigh), forecast.Units.Temperature, ansiReset,
+			day.Summary))
+	}
+	for _, alert := range forecast.Alerts {
+		lines = append(lines, ansiRed+ansiBold+"! "+alert.Title+ansiReset)
+	}
+	return lines
+}
+
+func (t *tui) wotdLines(width int) []string {
+	wotdInfo := t.state.Data.Wotd
+	if wotdInfo == nil {
+		return []string{ansiDim + "No word yet" + ansiReset}
+	}
+	lines := []string{
+		ansiBold + ansiYellow + wotdInfo.Word + ansiReset + "  \\" + wotdInfo.Pronounce + "\\  " + ansiDim + wotdInfo.POS + ansiReset,
+	}
+	for i, def := range wotdInfo.Defs {
+		prefix := strconv.Itoa(i+1) + ". "
+		for j, line := range wrapRunes(erase(def, ":"), width-len(prefix)) {
+			if j > 0 {
+				prefix = strings.Repeat(" ", len(prefix))
+			}
+			lines = append(lines, prefix+line)
+		}
+	}
+	if wotdInfo.Example != "" {
+		for _, line := range wrapRunes("“"+wotdInfo.Example+"”", width) {
+			lines = append(lines, ansiDim+line+ansiReset)
+		}
+	}
+	return lines
+}
+
+func (t *tui) statusLines() []string {
+	if len(t.modules) == 0 {
+		return []string{ansiDim + "No modules reported" + ansiReset}
+	}
+	var lines []string
+	for i, name := range t.modules {
+		status := t.state.Status[name]
+		line := fmt.Sprintf("[%d] %-10s ", i+1, name)
+		switch {
+		case status.Failures > 0:
+			line += ansiRed + fmt.Sprintf("failing (%d): %s", status.Failures, status.LastError) + ansiReset
+		case status.LastSuccess.IsZero():
+			line += ansiDim + "waiting" + ansiReset
+		default:
+			line += ansiGreen + "ok" + ansiReset
+		}
+		if !status.LastSuccess.IsZero() {
+			line += ansiDim + "  updated " + status.LastSuccess.In(plannerLocation).Format("15:04:05") + ansiReset
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// box frames body with a titled border width columns wide.
+func box(title string, width int, body []string) []string {
+	inner := width - 2
+	top := "┌─ " + ansiBold + title + ansiReset + " " + strings.Repeat("─", maxInt(inner-3-utf8.RuneCountInString(title), 0)) + "┐"
+	lines := []string{top}
+	for _, line := range body {
+		lines = append(lines, "│ "+fitLine(line, inner-2)+" │")
+	}
+	return append(lines, "└"+strings.Repeat("─", inner)+"┘")
+}
+
+// fitLine pads or cuts s to exactly width visible columns, passing ANSI
+// escape sequences through untouched.
+func fitLine(s string, width int) string {
+	var b strings.Builder
+	visible := 0
+	cut := false
+	for i := 0; i < len(s); {
+		if s[i] == '\x1b' {
+			end := strings.IndexByte(s[i:], 'm')
+			if end < 0 {
+				break
+			}
+			b.WriteString(s[i : i+end+1])
+			i += end + 1
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if visible == width {
+			cut = true
+			break
+		}
+		b.WriteRune(r)
+		visible++
+		i += size
+	}
+	if cut {
+		b.WriteString(ansiReset)
+	}
+	b.WriteString(strings.Repeat(" ", width-visible))
+	return b.String()
+}
+
+// wrapRunes breaks s into lines of at most width characters.
+func wrapRunes(s string, width int) []string {
+	var lines []string
+	var line string
+	for _, word := range strings.Fields(s) {
+		candidate := word
+		if line != "" {
+			candidate = line + " " + word
+		}
+		if line != "" && utf8.RuneCountInString(candidate) > width {
+			lines = append(lines, line)
+			candidate = word
+		}
+		line = candidate
+	}
+	if line != "" {
+		lines = append(lines, line)
+	}
+	return lines
+}
@@ -0,0 +1,377 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+//Define structures to receive weather forecast from JSON
+type current struct {
+	Time                 uint    `json:"time"`                 //	1453402675,
+	Summary              string  `json:"summary"`              //	"Rain",
+	Icon                 string  `json:"icon"`                 //	"rain",
+	NearestStormDistance uint    `json:"nearestStormDistance"` //	0,
+	PrecipIntensity      float64 `json:"precipIntensity"`      //	0.1685,
+	PrecipIntensityError float64 `json:"precipIntensityError"` //	0.0067,
+	PrecipProbability    float64 `json:"precipProbability"`    //	1,
+	PrecipType           string  `json:"precipType"`           //	"rain",
+	Temperature          float64 `json:"temperature"`          //	48.71,
+	ApparentTemperature  float64 `json:"apparentTemperature"`  //	46.93,
+	Dewpoint             float64 `json:"dewPoint"`             //	47.7,
+	Humidity             float64 `json:"humidity"`             //	0.96,
+	WindSpeed            float64 `json:"windSpeed"`            //	4.64,
+	WindBearing          int     `json:"windBearing"`          //	186,
+	Visibility           float64 `json:"visibility"`           //	4.3,
+	CloudCover           float64 `json:"cloudCover"`           //	0.73,
+	Pressure             float64 `json:"pressure"`             //	1009.7,
+	Ozone                float64 `json:"ozone"`                //	328.35
+}
+
+type dailyData struct {
+	Time                          uint64  `json:"time"`        //	1453402675,
+	Summary                       string  `json:"summary"`     //	"Rain",
+	Icon                          string  `json:"icon"`        //	"rain",
+	SunriseTime                   uint    `json:"sunriseTime"` //	1453391560,
+	SunsetTime                    uint    `json:"sunsetTime"`  //	1453424361,
+	MoonPhase                     float64 `json:"moonPhase"`   //	0.43
+	PrecipIntensity               float64 `json:"precipIntensity"`
+	PrecipitationIntensityMax     float64 `json:"precipIntensityMax"`
+	PrecipitationIntensityMaxTime float64 `json:"precipIntensityMaxTime"`
+	PrecipProbability             float64 `json:"precipProbability"`           //	1,
+	PrecipType                    string  `json:"precipType"`                  //	"rain",
+	TemperatureHigh               float64 `json:"temperatureHigh"`             //	41.42,
+	TemperatureHighTime           uint    `json:"temperatureHighTime"`         //	1453417200
+	TemperatureLow                float64 `json:"temperatureLow"`              //	41.42,
+	TemperatureLowTime            uint    `json:"temperatureLowTime"`          //	1453417200
+	ApparentTemperatureHigh       float64 `json:"apparentTemperatureHigh"`     //	46.93,
+	ApparentTemperatureHighTime   float64 `json:"apparentTemperatureHighTime"` //	46.93,
+	ApparentTemperatureLow        float64 `json:"apparentTemperatureLow"`      //	46.93,
+	ApparentTemperatureLowTime    float64 `json:"apparentTemperatureLowTime"`  //	46.93,
+	Dewpoint                      float64 `json:"dewPoint"`                    //	47.7,
+	Humidity                      float64 `json:"humidity"`                    //	0.96,
+	Pressure                      float64 `json:"pressure"`
+	WindSpeed                     float64 `json:"windSpeed"` //	4.64,
+	WindGust                      float64 `json:"windGust"`
+	WindGustTime                  float64 `json:"windGustTime"`
+	WindBearing                   int     `json:"windBearing"` //	186,
+	CloudCover                    float64 `json:"cloudCover"`
+	UVIndex                       float64 `json:"uvIndex"`
+	UVIndexTime                   float64 `json:"uvIndexTime"`
+	Visibility                    float64 `json:"visibility"`                 //	4.3,
+	Ozone                         float64 `json:"ozone"`                      //	328.35
+	TemperatureMin                float64 `json:"temperatureMin"`             //	41.42,
+	TemperatureMinTime            uint    `json:"temperatureMinTime"`         //	1453417200
+	TemperatureMax                float64 `json:"temperatureMax"`             //	41.42,
+	TemperatureMaxTime            uint    `json:"temperatureMaxTime"`         //	1453417200
+	ApparentTemperatureMin        float64 `json:"apparentTemperatureMin"`     //	46.93,
+	ApparentTemperatureMinTime    float64 `json:"apparentTemperatureMinTime"` //	46.93,
+	ApparentTemperatureMax        float64 `json:"apparentTemperatureMax"`     //	46.93,
+	ApparentTemperatureMaxTime    float64 `json:"apparentTemperatureMaxTime"` //	46.93,
+}
+
+type daily struct {
+	Summary string      `json:"summary"` //	"Rain for the hour.",
+	Icon    string      `json:"icon"`    //	"rain",
+	Data    []dailyData `json:"data"`
+}
+
+type hourlyData struct {
+	Time                uint64  `json:"time"`                //	1453402800,
+	Summary             string  `json:"summary"`             //	"Drizzle",
+	Icon                string  `json:"icon"`                //	"rain",
+	PrecipProbability   float64 `json:"precipProbability"`   //	0.61,
+	PrecipType          string  `json:"precipType"`          //	"rain",
+	Temperature         float64 `json:"temperature"`         //	48.71,
+	ApparentTemperature float64 `json:"apparentTemperature"` //	46.93,
+	WindSpeed           float64 `json:"windSpeed"`           //	4.64,
+}
+
+type hourly struct {
+	Summary string       `json:"summary"` //	"Rain until this evening.",
+	Icon    string       `json:"icon"`    //	"rain",
+	Data    []hourlyData `json:"data"`
+}
+
+type alert struct {
+	Title       string `json:"title"`       //	"Flood Watch for Mason, WA",
+	Time        uint   `json:"time"`        //	1453375020,
+	Expires     uint   `json:"expires"`     //	1453407300,
+	Description string `json:"description"` //	"...FLOOD WATCH...\n",
+	URL         string `json:"uri"`         //	"http:/..."
+}
+
+type darkskyForecast struct {
+	Latitude  float64 `json:"latitude"`  //	40.47780682531368,
+	Longitude float64 `json:"longitude"` //	-86.93875375799722,
+	Timezone  string  `json:"timezone"`  //	"America/Indiana/Indianapolis",
+	Current   current `json:"currently"`
+	Hourly    hourly
+	Daily     daily
+	Alerts    []alert
+	Offset    int          `json:"offset"` //	-4
+	Units     weatherUnits `json:"units"`  // from the settings, DarkSky only reports them in flags
+} // End of receiving structure for weather forecast
+
+type weatherSettings struct {
+	DarkSkyKey     string
+	Latitude       string
+	Longitude      string
+	Excludes       string
+	URL            string
+	ReloadInterval int // hours
+	Days           int
+	Language       string
+	Timezone       string // overrides the zone reported with the forecast
+	Units          string // DarkSky's "us" (the default), "si", "ca" or "uk2"
+}
+
+// weatherUnits are what a forecast's numbers are measured in.
+type weatherUnits struct {
+	Temperature string `json:"temperature"`
+	Speed       string `json:"speed"`
+	Distance    string `json:"distance"`
+}
+
+// darkskyUnits are the units of each of DarkSky's units options.
+var darkskyUnits = map[string]weatherUnits{
+	"us":  {"°F", "mph", "mi."},
+	"si":  {"°C", "m/s", "km"},
+	"ca":  {"°C", "km/h", "km"},
+	"uk2": {"°C", "mph", "mi."},
+}
+
+type weatherWidget struct {
+	settings weatherSettings
+}
+
+func init() {
+	registerWidget("weather", func() Widget { return &weatherWidget{} })
+}
+
+func (w *weatherWidget) Name() string { return "weather" }
+
+func (w *weatherWidget) Settings() interface{} { return &w.settings }
+
+func (w *weatherWidget) Configure(config configStruct) error {
+	if w.settings.DarkSkyKey == "" {
+		w.settings.DarkSkyKey = config.DarkSkyKey
+	}
+	if w.settings.Latitude == "" {
+		w.settings.Latitude = config.Latitude
+	}
+	if w.settings.Longitude == "" {
+		w.settings.Longitude = config.Longitude
+	}
+	if w.settings.Excludes == "" {
+		w.settings.Excludes = config.Excludes
+	}
+	if w.settings.URL == "" {
+		w.settings.URL = config.WeatherURL
+	}
+	if w.settings.ReloadInterval == 0 {
+		w.settings.ReloadInterval = config.WeatherReloadInterval
+	}
+	if w.settings.ReloadInterval <= 0 {
+		w.settings.ReloadInterval = 1
+	}
+	if w.settings.Days == 0 {
+		w.settings.Days = 3
+	}
+	if w.settings.Language == "" {
+		w.settings.Language = activeLocale.Language
+	}
+	if w.settings.Units == "" {
+		w.settings.Units = "us"
+	}
+	if _, ok := darkskyUnits[w.settings.Units]; !ok {
+		return fmt.Errorf("unknown units %q", w.settings.Units)
+	}
+	if w.settings.Timezone != "" {
+		_, err := time.LoadLocation(w.settings.Timezone)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *weatherWidget) Interval() time.Duration {
+	return time.Hour * time.Duration(w.settings.ReloadInterval)
+}
+
+func (w *weatherWidget) Fetch() (interface{}, error) {
+	darkskyURL := w.settings.URL + w.settings.DarkSkyKey + "/" + w.settings.Latitude + "," + w.settings.Longitude + "?" + w.settings.Excludes + "&lang=" + url.QueryEscape(w.settings.Language) + "&units=" + url.QueryEscape(w.settings.Units)
+	forecast, err := getForecast(darkskyURL)
+	if err != nil {
+		return nil, err
+	}
+	forecast.Units = darkskyUnits[w.settings.Units]
+	if w.settings.Timezone != "" {
+		forecast.Timezone = w.settings.Timezone
+	}
+	if len(forecast.Daily.Data) > w.settings.Days {
+		forecast.Daily.Data = forecast.Daily.Data[:w.settings.Days]
+	}
+
+	log.Println("  INFO: Finished getWeather()")
+	return forecast, nil
+}
+
+func (w *weatherWidget) Template() string { return weatherTemplate }
+
+func (w *weatherWidget) Health() error {
+	if w.settings.DarkSkyKey == "" {
+		return errors.New("no darkSkyKey configured")
+	}
+	return nil
+}
+
+const weatherTemplate = `
+<div id="weatherTitles">
+    <div id="currentTitle">
+        <h2>{{t "Current"}}<br>{{t "Conditions"}}</h2>
+    </div>
+    {{- range .Daily.Data}}
+    <div class="forecastTitle">
+        <h2>{{weekday .Time $.Timezone}}</h2>
+    </div>
+    {{- end}}
+</div>
+<div id="weatherContent">
+    <div id="currentContent">
+        <div class="contentLabels">
+            {{t "Temperature:"}}
+            <br> {{t "Humidity:"}}
+            <br> {{t "Winds:"}}
+            <br> {{t "Visibility:"}}
+        </div>
+        <div class="contentItems">
+            {{number .Current.Temperature 0}} {{.Units.Temperature}}
+            <br> {{percent .Current.Humidity}} %
+            <br> {{number .Current.WindSpeed 0}} {{.Units.Speed}}
+            <br> {{number .Current.Visibility 0}} {{.Units.Distance}}
+        </div>
+    </div>
+    {{- range .Daily.Data}}
+    <div class="forecastContent">
+        <div class="contentLabels">
+            {{t "Low:"}}
+            <br> {{t "High:"}}
+            <br> {{t "Humidity:"}}
+            <br> {{t "Winds:"}}
+            <br> {{t "Visibility:"}}
+        </div>
+        <div class="contentItems">
+            {{number .TemperatureLow 0}} {{$.Units.Temperature}}
+            <br> {{number .TemperatureHigh 0}} {{$.Units.Temperature}}
+            <br> {{percent .Humidity}} %
+            <br> {{number .WindSpeed 0}} {{$.Units.Speed}}
+            <br> {{number .Visibility 0}} {{$.Units.Distance}}
+        </div>
+    </div>
+    {{- end}}
+</div>
+`
+
+// getForecast downloads the forecast, keeps a pretty-printed copy in
+// json/darksky.json for debugging and decodes it.
+func getForecast(darkskyURL string) (darkskyForecast, error) {
+	var forecast darkskyForecast
+
+	data, err := http.Get(darkskyURL)
+	if err != nil {
+		return forecast, fmt.Errorf("reading forecast: %v", err)
+	}
+
+	// Convert raw data to []bytes.
+	dataBYTES, err := ioutil.ReadAll(data.Body)
+	data.Body.Close()
+	if err != nil {
+		return forecast, fmt.Errorf("reading forecast body: %v", err)
+	}
+	if data.StatusCode != http.StatusOK {
+		return forecast, fmt.Errorf("forecast request returned %s", data.Status)
+	}
+
+	var prettyJSON bytes.Buffer
+	err = json.Indent(&prettyJSON, dataBYTES, "", "    ")
+	if err != nil {
+		log.Println("  INFO: Error pretty printing JSON")
+	} else {
+		err = ioutil.WriteFile("json/darksky.json", prettyJSON.Bytes(), 0644)
+		if err != nil {
+			log.Println("  INFO: Error writing 'json/darksky.json':", err)
+		}
+	}
+
+	err = json.Unmarshal(dataBYTES, &forecast)
+	if err != nil {
+		return forecast, fmt.Errorf("unmarshaling forecast: %v", err)
+	}
+
+	log.Println("  INFO: Finished getForecastData()")
+	return forecast, nil
+}
+
+func (w *weatherWidget) ImageHeight(c *canvas, width int, data interface{}) int {
+	s := c.scale
+	return lineHeight(2*s) + 24*s + 4*lineHeight(s)
+}
+
+// DrawImage draws the current conditions and each forecast day as columns.
+// Visibility is left out so the columns stay legible at 800 pixels wide.
+func (w *weatherWidget) DrawImage(c *canvas, area image.Rectangle, data interface{}) {
+	forecast, ok := data.(darkskyForecast)
+	if !ok {
+		return
+	}
+	s := c.scale
+	number := func(x float64) string { return activeLocale.formatNumber(x, 0) }
+	columns := 1 + len(forecast.Daily.Data)
+	colWidth := area.Dx() / columns
+
+	column := func(i int, title string, icon string, lines []string) {
+		col := image.Rect(area.Min.X+i*colWidth, area.Min.Y, area.Min.X+(i+1)*colWidth, area.Max.Y)
+		y := col.Min.Y
+		c.textCentered(col, y, title, fitScale(title, col.Dx(), 2*s), inkBlack)
+		y += lineHeight(2 * s)
+		c.icon(icon, col.Min.X+(col.Dx()-20*s)/2, y, 20*s)
+		y += 24 * s
+		scale := s
+		for _, line := range lines {
+			if fit := fitScale(line, col.Dx(), s); fit < scale {
+				scale = fit
+			}
+		}
+		for _, line := range lines {
+			c.textCentered(col, y, line, scale, inkBlack)
+			y += lineHeight(s)
+		}
+	}
+
+	degrees := " " + strings.TrimPrefix(forecast.Units.Temperature, "°")
+	speed := " " + forecast.Units.Speed
+	current := forecast.Current
+	column(0, activeLocale.translate("Current"), current.Icon, []string{
+		number(current.Temperature) + degrees,
+		activeLocale.translate("Humidity:") + " " + number(current.Humidity*100) + " %",
+		activeLocale.translate("Winds:") + " " + number(current.WindSpeed) + speed,
+	})
+	for i, day := range forecast.Daily.Data {
+		column(i+1, getWeekday(day.Time, forecast.Timezone), day.Icon, []string{
+			activeLocale.translate("Low:") + " " + number(day.TemperatureLow) + degrees,
+			activeLocale.translate("High:") + " " + number(day.TemperatureHigh) + degrees,
+			activeLocale.translate("Humidity:") + " " + number(day.Humidity*100) + " %",
+			activeLocale.translate("Winds:") + " " + number(day.WindSpeed) + speed,
+		})
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"time"
+)
+
+// Widget is one self-contained panel of the planner. The scheduler calls
+// Fetch every Interval and publishes the result into the state store; the
+// renderer executes Template with that result to produce the panel's HTML.
+type Widget interface {
+	// Name is the key used in config, the state store and the page.
+	Name() string
+
+	// Settings returns a pointer to the widget's settings struct. The
+	// matching "widgets" entry of config.json is decoded into it.
+	Settings() interface{}
+
+	// Configure is called once the settings are decoded. It fills in
+	// defaults, including those taken from the top-level config.
+	Configure(config configStruct) error
+
+	// Interval is how long to wait before the next Fetch. It is asked
+	// again after every fetch, so a widget can line itself up with the
+	// clock.
+	Interval() time.Duration
+
+	// Fetch loads fresh data for the widget.
+	Fetch() (interface{}, error)
+
+	// Template is an html/template fragment rendered with Fetch's result.
+	Template() string
+
+	// Health reports problems the widget can detect on its own, such as a
+	// missing API key or an empty photo directory. nil means healthy.
+	Health() error
+}
+
+// backgroundWidget is implemented by widgets with work of their own to do
+// while the planner serves, beside fetching, such as announcing something at
+// a set time.
+type backgroundWidget interface {
+	Background(store *stateStore)
+}
+
+// fetchedWidget is implemented by widgets that keep something of every
+// successful fetch, such as the word archive. Only the server's runWidget
+// calls Fetched, so one-shot commands leave that state alone.
+type fetchedWidget interface {
+	Fetched(data interface{})
+}
+
+var widgetFactories = make(map[string]func() Widget)
+
+// registerWidget makes a widget available by name. Widgets register
+// themselves from init, so adding one never touches main().
+func registerWidget(name string, factory func() Widget) {
+	if _, exists := widgetFactories[name]; exists {
+		log.Fatalf("  FATAL: Widget %q registered twice\n", name)
+	}
+	widgetFactories[name] = factory
+}
+
+// loadWidgets builds and configures the named widgets.
+func loadWidgets(config configStruct, names []string) []Widget {
+	var widgets []Widget
+	for _, name := range names {
+		factory, ok := widgetFactories[name]
+		if !ok {
+			log.Fatalf("  FATAL: Unknown widget %q in config\n", name)
+		}
+		widget := factory()
+
+		if raw, ok := config.Widgets[name]; ok {
+			err := json.Unmarshal(raw, widget.Settings())
+			if err != nil {
+				log.Fatalf("  FATAL: Error unmarshaling settings for widget %q: %v\n", name, err)
+			}
+		}
+		err := widget.Configure(config)
+		if err != nil {
+			log.Fatalf("  FATAL: Error configuring widget %q: %v\n", name, err)
+		}
+
+		widgets = append(widgets, widget)
+	}
+	return widgets
+}
+
+func widgetsByName(widgets []Widget) map[string]Widget {
+	byName := make(map[string]Widget, len(widgets))
+	for _, widget := range widgets {
+		byName[widget.Name()] = widget
+	}
+	return byName
+}
+
+// refreshes holds a channel per running widget that cuts its wait short.
+// serve fills it in before it starts the widgets and the server; one-shot
+// commands leave it empty, so requestRefresh does nothing there.
+var refreshes = make(map[string]chan struct{})
+
+// requestRefresh asks a running widget to fetch now. It reports whether the
+// widget is running.
+func requestRefresh(name string) bool {
+	refresh, ok := refreshes[name]
+	if !ok {
+		return false
+	}
+	select {
+	case refresh <- struct{}{}:
+	default:
+	}
+	return true
+}
+
+// runWidget fetches on the widget's schedule until the program exits.
+func runWidget(widget Widget, store *stateStore) {
+	load := func() {
+		data, ok := fetchWidget(widget, store)
+		if fetched, isFetched := widget.(fetchedWidget); ok && isFetched {
+			fetched.Fetched(data)
+		}
+	}
+	log.Printf("  INFO: Initial %s load\n", widget.Name())
+	load()
+
+	for {
+		select {
+		case <-time.After(widget.Interval()):
+			log.Printf("  INFO: Periodic %s load\n", widget.Name())
+		case <-refreshes[widget.Name()]:
+			log.Printf("  INFO: Requested %s load\n", widget.Name())
+		}
+		load()
+	}
+}
+
+// fetchWidget fetches once and stores the outcome. It reports whether the
+// fetch succeeded.
+func fetchWidget(widget Widget, store *stateStore) (interface{}, bool) {
+	data, err := widget.Fetch()
+	if err != nil {
+		log.Printf("  INFO: Error fetching %s: %v\n", widget.Name(), err)
+		store.setError(widget.Name(), err)
+		return nil, false
+	}
+	store.set(widget.Name(), data)
+	return data, true
+}
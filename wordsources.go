@@ -0,0 +1,330 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"html"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// wotdSource is one place the word of the day can come from. Every source
+// normalizes its word into wotdType, so the widget can fall back from one
+// to the next without the page noticing.
+type wotdSource interface {
+	Name() string
+
+	// Word returns today's word.
+	Word() (wotdType, error)
+
+	// Health reports configuration problems, such as a missing key.
+	Health() error
+}
+
+// wotdSourceSettings is one entry of the wotd widget's "sources" list.
+// Type is "merriam-webster", "merriam-webster-feed", "wordnik",
+// "wiktionary", "local" or "url"; the other fields apply to some types only.
+// A merriam-webster source given a File or Lists takes its words from
+// them instead of the feed.
+type wotdSourceSettings struct {
+	Type       string
+	URL        string
+	Key        string
+	RSS        string
+	Format     string
+	Dictionary string // "collegiate" (the default), "intermediate" or "elementary"
+	File       string
+	Lists      []wordList
+	Language   string // "es", "fr" or "de" for a word in that language; for url it only labels the word
+}
+
+func newWotdSource(source wotdSourceSettings, settings wotdSettings) (wotdSource, error) {
+	switch source.Type {
+	case "merriam-webster":
+		s := &mwSource{rss: source.RSS, url: source.URL, key: source.Key, format: source.Format, audioURL: settings.AudioURL, audioDir: settings.audioDir, maxSenses: settings.MaxSenses}
+		if s.rss == "" {
+			s.rss = settings.RSS
+		}
+		if s.key == "" {
+			s.key = settings.Key
+		}
+		if s.format == "" {
+			s.format = settings.Format
+		}
+		if s.format == "" {
+			s.format = "xml"
+		}
+		format, ok := mwFormats[s.format]
+		if !ok {
+			return nil, fmt.Errorf("unknown Merriam-Webster format %q, use \"xml\" or \"json\"", s.format)
+		}
+		dictionary, ok := mwDictionaries[source.Dictionary]
+		if source.Dictionary == "" {
+			dictionary = mwDictionaries["collegiate"]
+		} else if !ok {
+			return nil, fmt.Errorf("unknown Merriam-Webster dictionary %q", source.Dictionary)
+		}
+		// The shared mwURL is a Collegiate endpoint; a source with a
+		// dictionary of its own uses that dictionary's.
+		if s.url == "" && source.Dictionary == "" {
+			s.url = settings.URL
+		}
+		if s.url == "" {
+			s.url = fmt.Sprintf(format.url, dictionary)
+		}
+		if source.File != "" || len(source.Lists) > 0 {
+			words, err := newLocalWordSource(source)
+			if err != nil {
+				return nil, err
+			}
+			s.words = words
+		}
+		if s.audioURL == "" {
+			s.audioURL = format.audioURL
+		}
+		return s, nil
+	case "merriam-webster-feed":
+		s := &mwFeedSource{rss: source.RSS}
+		if s.rss == "" {
+			s.rss = settings.RSS
+		}
+		return s, nil
+	case "wordnik":
+		s := &wordnikSource{url: source.URL, key: source.Key}
+		if s.url == "" {
+			s.url = "https://api.wordnik.com/v4/words.json/wordOfTheDay"
+		}
+		return s, nil
+	case "wiktionary":
+		s := &wiktionarySource{url: source.URL}
+		if s.url == "" {
+			s.url = "https://en.wiktionary.org/w/api.php?action=featuredfeed&feed=wotd&feedformat=atom"
+		}
+		return s, nil
+	case "local":
+		return newLocalWordSource(source)
+	case "url":
+		if source.URL == "" {
+			return nil, errors.New("url word sources need a url")
+		}
+		return &urlWordSource{url: source.URL, key: source.Key, language: source.Language}, nil
+	}
+	return nil, fmt.Errorf("unknown word source %q", source.Type)
+}
+
+func newLocalWordSource(source wotdSourceSettings) (*localWordSource, error) {
+	for _, list := range source.Lists {
+		if list.File == "" || list.From == "" {
+			return nil, errors.New("word lists need a file and a from date")
+		}
+	}
+	if source.File == "" && source.Language != "" {
+		_, err := loadDictionary("", source.Language)
+		if err != nil {
+			return nil, fmt.Errorf("no bundled word list for language %q", source.Language)
+		}
+	}
+	return &localWordSource{file: source.File, lists: source.Lists, language: source.Language}, nil
+}
+
+// urlWordSource fetches the word from any URL that answers with a word in
+// the local dictionary format, such as a list the family keeps online.
+// "{date}" in the URL is replaced by today's date and "{key}" by the key; an
+// answer with a list of words gives one word a day. The language does not
+// choose a list, it only marks the word's language; translations keyed by
+// language are shown in the household's.
+type urlWordSource struct {
+	url      string
+	key      string
+	language string
+}
+
+func (s *urlWordSource) Name() string { return "url" }
+
+func (s *urlWordSource) Health() error { return nil }
+
+func (s *urlWordSource) Word() (wotdType, error) {
+	now := time.Now()
+	address := strings.Replace(s.url, "{date}", now.In(plannerLocation).Format("2006-01-02"), -1)
+	address = strings.Replace(address, "{key}", url.QueryEscape(s.key), -1)
+	data, err := httpGetBytes(address)
+	if err != nil {
+		return wotdType{}, err
+	}
+
+	var entries []dictionaryWord
+	if strings.HasPrefix(strings.TrimSpace(string(data)), "[") {
+		err = json.Unmarshal(data, &entries)
+	} else {
+		var entry dictionaryWord
+		err = json.Unmarshal(data, &entry)
+		entries = append(entries, entry)
+	}
+	if err != nil {
+		return wotdType{}, fmt.Errorf("unmarshaling word: %v", err)
+	}
+	if len(entries) == 0 {
+		return wotdType{}, errors.New("no words")
+	}
+	word := entries[dayNumber(now)%len(entries)].wotd()
+	word.Language = s.language
+	return word, nil
+}
+
+// wordnikSource is Wordnik's word of the day, which needs a free API key.
+type wordnikSource struct {
+	url string
+	key string
+}
+
+type wordnikWord struct {
+	Word        string `json:"word"`
+	Definitions []struct {
+		Text         string `json:"text"`
+		PartOfSpeech string `json:"partOfSpeech"`
+	} `json:"definitions"`
+}
+
+func (s *wordnikSource) Name() string { return "wordnik" }
+
+func (s *wordnikSource) Health() error {
+	if s.key == "" {
+		return errors.New("no Wordnik key configured")
+	}
+	return nil
+}
+
+func (s *wordnikSource) Word() (wotdType, error) {
+	if s.key == "" {
+		return wotdType{}, errors.New("no Wordnik key configured")
+	}
+	date := time.Now().In(plannerLocation).Format("2006-01-02")
+	data, err := httpGetBytes(s.url + "?date=" + date + "&api_key=" + url.QueryEscape(s.key))
+	if err != nil {
+		return wotdType{}, err
+	}
+	var word wordnikWord
+	err = json.Unmarshal(data, &word)
+	if err != nil {
+		return wotdType{}, fmt.Errorf("unmarshaling Wordnik word of the day: %v", err)
+	}
+
+	wotdInfo := wotdType{Word: word.Word}
+	for _, def := range word.Definitions {
+		if wotdInfo.POS == "" {
+			wotdInfo.POS = def.PartOfSpeech
+		}
+		wotdInfo.Defs = append(wotdInfo.Defs, htmlText(def.Text))
+	}
+	return wotdInfo, nil
+}
+
+// wiktionarySource is the English Wiktionary's featured word, read from its
+// Atom feed. The feed has no pronunciations.
+type wiktionarySource struct {
+	url string
+}
+
+type atomFeed struct {
+	Entries []struct {
+		Title   string `xml:"title"`
+		Updated string `xml:"updated"`
+		Summary string `xml:"summary"`
+	} `xml:"entry"`
+}
+
+var wiktionaryTitle = regexp.MustCompile(`(?s)id="WOTD-rss-title"[^>]*>(.*?)</span>`)
+
+func (s *wiktionarySource) Name() string { return "wiktionary" }
+
+func (s *wiktionarySource) Health() error { return nil }
+
+func (s *wiktionarySource) Word() (wotdType, error) {
+	data, err := httpGetBytes(s.url)
+	if err != nil {
+		return wotdType{}, err
+	}
+	var feed atomFeed
+	err = xml.Unmarshal(data, &feed)
+	if err != nil {
+		return wotdType{}, fmt.Errorf("unmarshaling Wiktionary feed: %v", err)
+	}
+	if len(feed.Entries) == 0 {
+		return wotdType{}, errors.New("Wiktionary feed has no entries")
+	}
+
+	latest := feed.Entries[0]
+	for _, entry := range feed.Entries {
+		if entry.Updated > latest.Updated {
+			latest = entry
+		}
+	}
+
+	var wotdInfo wotdType
+	summary := latest.Summary
+	if match := wiktionaryTitle.FindStringSubmatchIndex(summary); match != nil {
+		wotdInfo.Word = htmlText(summary[match[2]:match[3]])
+		summary = summary[match[1]:]
+	} else {
+		// Entry titles read "Wiktionary:Word of the day/..." or just the word.
+		wotdInfo.Word = latest.Title[strings.LastIndexAny(latest.Title, ":/")+1:]
+	}
+	if pos := htmlElements(summary, "i"); len(pos) > 0 {
+		wotdInfo.POS = htmlText(pos[0])
+	}
+	if lists := htmlElements(summary, "ol"); len(lists) > 0 {
+		for _, item := range htmlElements(lists[0], "li") {
+			wotdInfo.Defs = append(wotdInfo.Defs, htmlText(item))
+		}
+	}
+	return wotdInfo, nil
+}
+
+var (
+	htmlBlockTag = regexp.MustCompile(`(?i)</?(p|br|div|li|ol|ul|h[1-6])\b[^>]*>`)
+	htmlTag      = regexp.MustCompile(`<[^>]*>`)
+)
+
+// htmlText is the text of an HTML fragment with tags removed, entities
+// decoded and whitespace collapsed. Block tags separate words; inline tags
+// do not.
+func htmlText(fragment string) string {
+	text := htmlTag.ReplaceAllString(htmlBlockTag.ReplaceAllString(fragment, " "), "")
+	return strings.Join(strings.Fields(html.UnescapeString(text)), " ")
+}
+
+// htmlElements returns the inner HTML of every tag element in fragment.
+// Elements of the same tag must not nest.
+func htmlElements(fragment string, tag string) []string {
+	open := regexp.MustCompile(`(?i)<` + tag + `(\s[^>]*)?>`)
+	closing := "</" + tag + ">"
+	var elements []string
+	for {
+		match := open.FindStringIndex(fragment)
+		if match == nil {
+			return elements
+		}
+		fragment = fragment[match[1]:]
+		end := strings.Index(strings.ToLower(fragment), closing)
+		if end < 0 {
+			return append(elements, fragment)
+		}
+		elements = append(elements, fragment[:end])
+		fragment = fragment[end+len(closing):]
+	}
+}
+
+// htmlParagraphs returns the text of each paragraph of fragment.
+func htmlParagraphs(fragment string) []string {
+	var paragraphs []string
+	for _, p := range htmlElements(fragment, "p") {
+		if text := htmlText(p); text != "" {
+			paragraphs = append(paragraphs, text)
+		}
+	}
+	return paragraphs
+}
@@ -0,0 +1,478 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type wotdType struct {
+	Word      string
+	Language  string // of the word, when it is not the household's
+	Pronounce string
+	POS       string
+	Gender    string // "masculine", "feminine" or "neuter", for some languages
+	Defs      []string
+	Entries   []wotdEntry // the definitions with their markup, when the source has it
+	Example   string
+	Source    string
+
+	Translation        string // of a foreign word into the household language
+	ExampleTranslation string
+
+	Audio     string // path of the cached pronunciation clip
+	Etymology string
+	Forms     []string // inflected forms, such as "transpired; transpiring"
+	Related   []relatedWord
+
+	Art        string // illustration URL
+	ArtCaption string
+
+	Track   string     // name of the track the word is for, if there are several
+	Members []string   // who the track is for
+	Tracks  []wotdType // the other tracks' words, beside this first one
+
+	MoreSenses int    // senses left out beyond the widget's maxSenses
+	MoreURL    string // where to read them
+}
+
+// wotdEntry is the senses of the word for one part of speech.
+type wotdEntry struct {
+	POS       string
+	Pronounce string
+	FirstUse  string
+	Senses    []wotdSense
+}
+
+// relatedWord is a run-on entry: a word formed from the word of the day,
+// with its own pronunciation and part of speech but no definitions.
+type relatedWord struct {
+	Word      string
+	Pronounce string
+	POS       string
+}
+
+// wotdSettings configures the word of the day. Sources are tried in order
+// until one returns a word; without any, the Merriam-Webster dictionary is
+// tried first, then its feed, then the bundled dictionary, which works
+// without a key or even a network.
+//
+// Tracks give different family members a word of their own, each track
+// with its own sources. The first track takes the place of Sources as the
+// panel's main word; the others are shown beside it or in turn with it.
+type wotdSettings struct {
+	RSS            string
+	URL            string
+	Key            string
+	Format         string // of the Merriam-Webster API, "xml" or "json"
+	ReloadInterval int    // hours
+	Sources        []wotdSourceSettings
+	AudioURL       string // base of the Merriam-Webster pronunciation clips
+	AutoPlay       string // "7:30" plays the word every morning at 7:30
+	MaxSenses      int    // senses shown before "more senses", 8 by default
+	Tracks         []wotdTrackSettings
+	TrackDisplay   string // "side-by-side" (the default) or "rotate"
+	RotateSeconds  int    // how long each track shows when rotating, 20 by default
+
+	audioDir string
+}
+
+type wotdTrackSettings struct {
+	Name    string
+	Sources []wotdSourceSettings
+}
+
+// wotdTrack is one word of the day with the sources it comes from.
+type wotdTrack struct {
+	name    string
+	members []string
+	sources []wotdSource
+}
+
+type wotdWidget struct {
+	settings wotdSettings
+	tracks   []*wotdTrack
+	archive  *wotdArchive
+	autoPlay time.Duration // since midnight, negative when off
+}
+
+func init() {
+	registerWidget("wotd", func() Widget { return &wotdWidget{} })
+}
+
+func (w *wotdWidget) Name() string { return "wotd" }
+
+func (w *wotdWidget) Settings() interface{} { return &w.settings }
+
+func (w *wotdWidget) Configure(config configStruct) error {
+	if w.settings.RSS == "" {
+		w.settings.RSS = config.MWrss
+	}
+	if w.settings.URL == "" {
+		w.settings.URL = config.MWurl
+	}
+	if w.settings.Key == "" {
+		w.settings.Key = config.MWkey
+	}
+	if w.settings.Format == "" {
+		w.settings.Format = config.MWformat
+	}
+	if w.settings.ReloadInterval == 0 {
+		w.settings.ReloadInterval = config.WotdReloadInterval
+	}
+	if w.settings.ReloadInterval <= 0 {
+		w.settings.ReloadInterval = 1
+	}
+
+	w.settings.audioDir = audioDir(config)
+	if w.settings.MaxSenses <= 0 {
+		w.settings.MaxSenses = 8
+	}
+	w.autoPlay = -1
+	if w.settings.AutoPlay != "" {
+		at, err := time.Parse("15:04", w.settings.AutoPlay)
+		if err != nil {
+			return fmt.Errorf("autoPlay %q is not a time such as 7:30", w.settings.AutoPlay)
+		}
+		w.autoPlay = time.Duration(at.Hour())*time.Hour + time.Duration(at.Minute())*time.Minute
+	}
+	switch w.settings.TrackDisplay {
+	case "", "side-by-side", "rotate":
+	default:
+		return fmt.Errorf("unknown trackDisplay %q, use \"side-by-side\" or \"rotate\"", w.settings.TrackDisplay)
+	}
+	if w.settings.RotateSeconds <= 0 {
+		w.settings.RotateSeconds = 20
+	}
+	w.archive = openWotdArchive(config.WotdArchive)
+
+	tracks := w.settings.Tracks
+	if len(tracks) == 0 {
+		tracks = []wotdTrackSettings{{Sources: w.settings.Sources}}
+	}
+	byName := make(map[string]*wotdTrack)
+	w.tracks = nil
+	for _, settings := range tracks {
+		track := &wotdTrack{name: settings.Name}
+		if len(w.settings.Tracks) > 0 && (track.name == "" || byName[track.name] != nil) {
+			return errors.New("every track needs a name of its own")
+		}
+		sources := settings.Sources
+		if len(sources) == 0 {
+			sources = []wotdSourceSettings{{Type: "merriam-webster"}, {Type: "merriam-webster-feed"}, {Type: "local"}}
+		}
+		for _, settings := range sources {
+			source, err := newWotdSource(settings, w.settings)
+			if err != nil {
+				return err
+			}
+			track.sources = append(track.sources, source)
+		}
+		byName[track.name] = track
+		w.tracks = append(w.tracks, track)
+	}
+	for _, member := range config.Members {
+		if member.Track == "" {
+			continue
+		}
+		track, ok := byName[member.Track]
+		if !ok {
+			return fmt.Errorf("member %s has unknown track %q", member.Name, member.Track)
+		}
+		track.members = append(track.members, member.Name)
+	}
+	return nil
+}
+
+func (w *wotdWidget) Interval() time.Duration {
+	return time.Hour * time.Duration(w.settings.ReloadInterval)
+}
+
+// Fetch returns the first track's word with the other tracks' words in its
+// Tracks. Only the first track is required.
+func (w *wotdWidget) Fetch() (interface{}, error) {
+	wotdInfo, err := w.tracks[0].word()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, track := range w.tracks[1:] {
+		word, err := track.word()
+		if err != nil {
+			log.Printf("  INFO: Error getting word of track %s: %v\n", track.name, err)
+			continue
+		}
+		wotdInfo.Tracks = append(wotdInfo.Tracks, word)
+	}
+	return wotdInfo, nil
+}
+
+// word returns the word from the first source that has one.
+func (t *wotdTrack) word() (wotdType, error) {
+	var problems []string
+	for _, source := range t.sources {
+		wotdInfo, err := source.Word()
+		if err == nil && wotdInfo.Word == "" {
+			err = errors.New("no word")
+		}
+		if err != nil {
+			log.Printf("  INFO: Word source %s failed: %v\n", source.Name(), err)
+			problems = append(problems, source.Name()+": "+err.Error())
+			continue
+		}
+
+		wotdInfo.Source = source.Name()
+		wotdInfo.Track = t.name
+		wotdInfo.Members = t.members
+		log.Printf("  INFO: Finished getWOTD() from %s\n", source.Name())
+		return wotdInfo, nil
+	}
+	return wotdType{}, fmt.Errorf("no word source succeeded (%s)", strings.Join(problems, "; "))
+}
+
+// Background plays the words' pronunciations on every connected page at
+// the autoPlay time, in the planner's timezone, one track after another.
+func (w *wotdWidget) Background(store *stateStore) {
+	if w.autoPlay < 0 {
+		return
+	}
+	for {
+		now := time.Now().In(plannerLocation)
+		next := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, plannerLocation).Add(w.autoPlay)
+		if !next.After(now) {
+			next = next.AddDate(0, 0, 1)
+		}
+		time.Sleep(next.Sub(now))
+
+		wotdInfo, _ := store.snapshot().Data["wotd"].(wotdType)
+		played := false
+		for _, word := range wotdInfo.all() {
+			if word.Audio == "" {
+				continue
+			}
+			log.Printf("  INFO: Playing pronunciation of %s\n", word.Word)
+			events.publish("play", "", map[string]string{"widget": "wotd", "src": word.Audio})
+			played = true
+		}
+		if !played {
+			log.Println("  INFO: No pronunciation to play")
+		}
+	}
+}
+
+// Fetched records every track's word in the archive under today's date.
+// The server calls it after every successful fetch, so commands such as
+// "planner fetch wotd" or "planner print" leave the archive alone.
+func (w *wotdWidget) Fetched(data interface{}) {
+	wotdInfo, ok := data.(wotdType)
+	if !ok {
+		return
+	}
+	today := time.Now().In(plannerLocation).Format("2006-01-02")
+	for _, word := range wotdInfo.all() {
+		w.archive.record(today, word)
+	}
+}
+
+// all is the first track's word followed by the other tracks' words.
+func (wotdInfo wotdType) all() []wotdType {
+	first := wotdInfo
+	first.Tracks = nil
+	return append([]wotdType{first}, wotdInfo.Tracks...)
+}
+
+// wotdMainTrack is the name of the first word of the day track, which
+// members without a track of their own follow. It is "" without tracks.
+func wotdMainTrack(config configStruct) string {
+	var settings wotdSettings
+	// A malformed wotd section is reported when the widget loads.
+	json.Unmarshal(config.Widgets["wotd"], &settings)
+	if len(settings.Tracks) == 0 {
+		return ""
+	}
+	return settings.Tracks[0].Name
+}
+
+// Template shows the tracks side by side or, when rotating, one at a time
+// with planner.js moving on to the next every RotateSeconds.
+func (w *wotdWidget) Template() string {
+	panel := `<div class="wotdTracks sideBySide">`
+	if w.settings.TrackDisplay == "rotate" {
+		panel = fmt.Sprintf(`<div class="wotdTracks rotate" data-seconds="%d">`, w.settings.RotateSeconds)
+	}
+	return wotdWordTemplate + `
+<h2><span id="wotd">{{t "Word of the Day"}}</span></h2>
+{{- if .Tracks}}
+` + panel + `
+    <div class="wotdTrack current">{{template "word" .}}</div>
+    {{- range .Tracks}}
+    <div class="wotdTrack">{{template "word" .}}</div>
+    {{- end}}
+</div>
+{{- else}}
+<div class="wotdTrack">{{template "word" .}}</div>
+{{- end}}
+`
+}
+
+// Health reports the problems of every source, even when a later source
+// covers for them.
+func (w *wotdWidget) Health() error {
+	var problems []string
+	for _, track := range w.tracks {
+		for _, source := range track.sources {
+			if err := source.Health(); err != nil {
+				name := source.Name()
+				if track.name != "" {
+					name = track.name + " " + name
+				}
+				problems = append(problems, name+": "+err.Error())
+			}
+		}
+	}
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// wotdWordTemplate shows one word; the widget's template shows one per
+// track.
+const wotdWordTemplate = `{{define "word"}}
+{{- with .Track}}
+<h3 class="trackName">{{.}}{{with $.Members}} &ndash; {{join . ", "}}{{end}}</h3>
+{{- end}}
+<div class="wotdTitle">
+    <span class="word"{{with .Language}} lang="{{.}}"{{end}}>{{.Word}}:&nbsp;</span>
+    <span class="pronounce">[&nbsp;&nbsp;{{.Pronounce}}&nbsp;]</span>
+    {{- with .Audio}}
+    <button class="play" type="button" onclick="this.nextElementSibling.play()" title="{{t "Play"}}">&#128264;</button><audio src="{{.}}" preload="none"></audio>
+    {{- end}}
+    <span class="pos">&nbsp;{{.POS}}{{with .Gender}}, {{t .}}{{end}}</span><br><br>
+</div>
+{{- with .Translation}}
+<p class="translation">&rarr; {{.}}</p>
+{{- end}}
+<span class="defs">
+    {{- if .Entries}}
+    {{- $grouped := gt (len .Entries) 1}}
+    {{- range .Entries}}
+    {{- if $grouped}}
+    <span class="entryPOS">{{.POS}}</span>{{with .Pronounce}} <span class="entryPronounce">[&nbsp;{{.}}&nbsp;]</span>{{end}}<br>
+    {{- end}}
+    {{- range .Senses}}
+    {{- with .Label}}
+    <span class="senseLabel">{{.}}</span><br>
+    {{- end}}
+    <span class="sense senseLevel{{.Level}}">{{with .Number}}<b class="senseNumber">{{.}}</b> {{end}}{{.HTML}}</span><br>
+    {{- end}}
+    {{- end}}
+    {{- else}}
+    {{- range $i, $def := .Defs -}}
+    &nbsp;&nbsp;&nbsp;{{t "Definition"}} {{inc $i}}) &nbsp;{{erase $def ":"}}<br>
+    {{- end -}}
+    {{- end}}
+</span>
+{{- if .MoreSenses}}
+<p class="moreSenses"><a href="{{.MoreURL}}">{{t "more senses"}} ({{.MoreSenses}})</a></p>
+{{- end}}
+{{- with .Entries}}{{with (index . 0).FirstUse}}
+<p class="firstUse">{{t "First known use:"}} {{.}}</p>
+{{- end}}{{end}}
+{{- if .Example}}
+<p class="example"{{with .Language}} lang="{{.}}"{{end}}>&ldquo;{{.Example}}&rdquo;</p>
+{{- end}}
+{{- with .ExampleTranslation}}
+<p class="exampleTranslation">{{.}}</p>
+{{- end}}
+{{- with .Art}}
+<figure class="wotdArt"><img src="{{.}}" alt="{{$.ArtCaption}}"><figcaption>{{$.ArtCaption}}</figcaption></figure>
+{{- end}}
+{{- with .Etymology}}
+<details class="wordMore">
+    <summary>{{t "Word origin"}}</summary>
+    <p>{{.}}</p>
+</details>
+{{- end}}
+{{- if or .Forms .Related}}
+<details class="wordMore">
+    <summary>{{t "Related forms"}}</summary>
+    {{- if .Forms}}
+    <p>{{join .Forms "; "}}</p>
+    {{- end}}
+    {{- range .Related}}
+    <p><span class="relatedWord">{{.Word}}</span>{{with .Pronounce}} [&nbsp;{{.}}&nbsp;]{{end}}{{with .POS}} <span class="relatedPOS">{{.}}</span>{{end}}</p>
+    {{- end}}
+</details>
+{{- end}}
+{{- end}}`
+
+// httpGetBytes returns the body of a successful GET request.
+func httpGetBytes(url string) ([]byte, error) {
+	data, err := http.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer data.Body.Close()
+
+	dataBYTES, err := ioutil.ReadAll(data.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %v", url, err)
+	}
+	if data.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s returned %s", url, data.Status)
+	}
+	return dataBYTES, nil
+}
+
+func (w *wotdWidget) ImageHeight(c *canvas, width int, data interface{}) int {
+	return w.drawImage(c, image.Rect(0, 0, width, 0), data, false)
+}
+
+func (w *wotdWidget) DrawImage(c *canvas, area image.Rectangle, data interface{}) {
+	w.drawImage(c, area, data, true)
+}
+
+// drawImage lays out the word of the day and returns the height it needs.
+// With draw false it only measures.
+func (w *wotdWidget) drawImage(c *canvas, area image.Rectangle, data interface{}, draw bool) int {
+	wotdInfo, ok := data.(wotdType)
+	if !ok {
+		return 0
+	}
+	s := c.scale
+	g := inkBlack
+	if !draw {
+		// Measuring draws outside the clip, leaving the image untouched.
+		saved := c.clip
+		c.clip = image.Rectangle{}
+		defer func() { c.clip = saved }()
+	}
+
+	y := area.Min.Y
+	title := activeLocale.translate("Word of the Day")
+	c.textCentered(area, y, title, fitScale(title, area.Dx(), 2*s), g)
+	y += lineHeight(2 * s)
+
+	c.textCentered(area, y, wotdInfo.Word, fitScale(wotdInfo.Word, area.Dx(), 3*s), g)
+	y += lineHeight(3 * s)
+	y += c.paragraph(area.Min.X, y, area.Dx(), "["+wotdInfo.Pronounce+"] "+wotdInfo.POS, s, g)
+	y += lineHeight(s) / 2
+
+	for i, def := range wotdInfo.Defs {
+		text := strconv.Itoa(i+1) + ") " + erase(def, ":")
+		y += c.paragraph(area.Min.X, y, area.Dx(), text, s, g)
+	}
+	if wotdInfo.Example != "" {
+		y += lineHeight(s) / 2
+		y += c.paragraph(area.Min.X, y, area.Dx(), "\""+wotdInfo.Example+"\"", s, g)
+	}
+	return y - area.Min.Y
+}